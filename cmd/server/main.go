@@ -1,35 +1,59 @@
 package main
 
 import (
+	"crypto/subtle"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"github.com/mlesniak/markdown/internal/cache"
 	"github.com/mlesniak/markdown/internal/dropbox"
 	"github.com/mlesniak/markdown/internal/handler"
+	"github.com/mlesniak/markdown/internal/mentions"
+	"github.com/mlesniak/markdown/internal/routes"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/theme"
 	"github.com/mlesniak/markdown/internal/utils"
 	"github.com/rs/zerolog"
 	"github.com/ziflex/lecho/v2"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const rootFilename = "202009010520 index.md"
+
+var rootFiles = []string{rootFilename, "202009010533 About me.md"}
+
 func main() {
-	const rootFilename = "202009010520 index.md"
-	rootFiles := []string{rootFilename, "202009010533 About me.md"}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
 
 	log := initializeLogger()
 	dropboxService := initializeDropbox(log)
+	siteTheme := initializeTheme(log)
 
 	e := initializeEcho(log)
-	e.Static("/static", "static/")
+	e.GET("/static/*", handler.StaticHandler(siteTheme.Static))
 	e.Static("/download", "download/")
 
-	e.GET("/", func(c echo.Context) error {
-		c.SetParamNames("name")
-		c.SetParamValues(rootFilename)
-		return handler.ContentHandler(dropboxService)(c)
-	})
-	e.GET("/:name", handler.ContentHandler(dropboxService))
+	e.GET("/", handler.IndexHandler(rootFilename))
+	e.GET("/search", handler.SearchHandler)
+	e.GET("/graph", handler.GraphPageHandler)
+	e.GET("/tags", handler.TagsHandler)
+	e.GET("/api/graph.json", handler.GraphHandler)
+	e.GET("/api/notes", handler.NotesHandler)
+	e.GET("/api/notes/:id", handler.NoteHandler)
+	e.GET("/api/recent", handler.RecentHandler)
+	e.GET("/api/backlinks/:id", handler.BacklinksHandler(dropboxService))
+	e.GET("/api/tags", handler.TagsAPIHandler)
+	e.GET("/api/tags/:name", handler.TagHandler)
+	e.GET("/api/openapi.json", handler.OpenAPIHandler)
+	e.GET("/:name", handler.ContentHandler(dropboxService, siteTheme.Static))
+	initializeAdmin(e, log, dropboxService)
 
 	// Prevent cache updates every time we change a file
 	var timer *time.Timer
@@ -59,8 +83,14 @@ func main() {
 	}))
 	e.GET("/dropbox/webhook", dropboxService.HandleChallenge)
 
-	e.Logger.Info("Initial cache storage starting...")
-	dropboxService.UpdateCache(rootFiles)
+	if dropboxService.LoadSnapshot() {
+		// Serve the last snapshot while refreshing it.
+		e.Logger.Info("Background cache update starting...")
+		go dropboxService.UpdateCache(rootFiles)
+	} else {
+		e.Logger.Info("Initial cache storage starting...")
+		dropboxService.UpdateCache(rootFiles)
+	}
 
 	e.Logger.Info("Starting to listen for requests")
 	e.Logger.Fatal(e.Start(":8080"))
@@ -76,6 +106,20 @@ func initializeEcho(log *lecho.Logger) *echo.Echo {
 	e.Use(lecho.Middleware(lecho.Config{
 		Logger: log,
 	}))
+	e.Use(handler.Redirects())
+
+	if value := os.Getenv("CACHE_BUDGET"); value != "" {
+		budget, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			panic("Invalid CACHE_BUDGET: " + value)
+		}
+		cache.Get().SetBudget(budget)
+	}
+	handler.SetCacheControl(handler.CacheControl{
+		HTML:   os.Getenv("CACHE_CONTROL_HTML"),
+		Media:  os.Getenv("CACHE_CONTROL_MEDIA"),
+		Static: os.Getenv("CACHE_CONTROL_STATIC"),
+	})
 
 	e.HideBanner = true
 	e.HidePort = true
@@ -109,6 +153,44 @@ func initializeLogger() *lecho.Logger {
 	)
 }
 
+// initializeAdmin registers the pages for administrators, which are only
+// available if ADMIN_PASSWORD is set.
+func initializeAdmin(e *echo.Echo, log echo.Logger, dropboxService *dropbox.Service) {
+	user := os.Getenv("ADMIN_USER")
+	if user == "" {
+		user = "admin"
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		log.Info("No admin password set, admin pages are disabled")
+		return
+	}
+
+	admin := e.Group("/admin", middleware.BasicAuth(func(u string, p string, c echo.Context) (bool, error) {
+		validUser := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		validPassword := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		return validUser && validPassword, nil
+	}))
+	admin.GET("/report", handler.ReportHandler(dropboxService, rootFiles))
+	admin.GET("/cache", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, cache.Get().Stats())
+	})
+}
+
+// initializeTheme loads the theme configured by THEME, a directory containing
+// templates/ and static/, or the embedded default theme.
+func initializeTheme(log echo.Logger) theme.Theme {
+	siteTheme, err := theme.Load(os.Getenv("THEME"))
+	if err != nil {
+		panic("Unable to load theme: " + err.Error())
+	}
+	if err := templates.Init(siteTheme.Templates); err != nil {
+		panic("Unable to parse templates: " + err.Error())
+	}
+	log.Infof("Using theme. theme=%s", siteTheme.Name)
+	return siteTheme
+}
+
 func initializeDropbox(log echo.Logger) *dropbox.Service {
 	dropboxToken := os.Getenv("TOKEN")
 	if dropboxToken == "" {
@@ -119,10 +201,80 @@ func initializeDropbox(log echo.Logger) *dropbox.Service {
 		panic("No dropbox app secret set, aborting.")
 	}
 
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://mlesniak.com"
+	}
+	minLength := 4
+	if value := os.Getenv("MENTIONS_MIN_LENGTH"); value != "" {
+		length, err := strconv.Atoi(value)
+		if err != nil {
+			panic("Invalid MENTIONS_MIN_LENGTH: " + value)
+		}
+		minLength = length
+	}
+	unpublishedLinkText := "[unpublished]"
+	if text, ok := os.LookupEnv("UNPUBLISHED_LINK_TEXT"); ok {
+		unpublishedLinkText = text
+	}
+	tagAliases := make(map[string]string)
+	for _, alias := range utils.SplitList(os.Getenv("TAG_ALIASES")) {
+		parts := strings.SplitN(alias, ":", 2)
+		if len(parts) != 2 {
+			panic("Invalid TAG_ALIASES entry: " + alias)
+		}
+		tagAliases[parts[0]] = parts[1]
+	}
+	utils.SetTagAliases(tagAliases)
+	utils.SetHiddenTags(utils.SplitList(os.Getenv("HIDDEN_TAGS")))
+	utils.SetHexTags(utils.SplitList(os.Getenv("HEX_TAGS")))
+	slugMode := routes.Filename
+	if mode := os.Getenv("SLUGS"); mode != "" {
+		slugMode = routes.Mode(mode)
+		if slugMode != routes.Filename && slugMode != routes.ID && slugMode != routes.Title {
+			panic("Invalid SLUGS: " + mode)
+		}
+	}
+	redirects := make(map[string]string)
+	if filename := os.Getenv("REDIRECTS"); filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			panic("Unable to open REDIRECTS: " + err.Error())
+		}
+		redirects, err = routes.ParseRedirects(file)
+		file.Close()
+		if err != nil {
+			panic("Unable to parse REDIRECTS: " + err.Error())
+		}
+	}
+	mediaCacheLimit := int64(2 << 20)
+	if value := os.Getenv("MEDIA_CACHE_LIMIT"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			panic("Invalid MEDIA_CACHE_LIMIT: " + value)
+		}
+		mediaCacheLimit = limit
+	}
+	robotsDisallow := []string{"/dropbox/"}
+	if disallow, ok := os.LookupEnv("ROBOTS_DISALLOW"); ok {
+		robotsDisallow = utils.SplitList(disallow)
+	}
+
 	return dropbox.New(dropbox.Service{
-		AppSecret:     dropboxAppSecret,
-		Token:         dropboxToken,
-		RootDirectory: "notes/",
-		Log:           log,
+		AppSecret:      dropboxAppSecret,
+		Token:          dropboxToken,
+		RootDirectory:  "notes/",
+		Log:            log,
+		BaseURL:        baseURL,
+		RobotsDisallow: robotsDisallow,
+		MentionOptions: mentions.Options{
+			MinLength: minLength,
+			Ignore:    utils.SplitList(os.Getenv("MENTIONS_IGNORE")),
+		},
+		UnpublishedLinkText: unpublishedLinkText,
+		SlugMode:            slugMode,
+		Redirects:           redirects,
+		MediaCacheLimit:     mediaCacheLimit,
+		SnapshotDirectory:   os.Getenv("SNAPSHOT_DIR"),
 	})
 }
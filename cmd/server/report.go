@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"github.com/mlesniak/markdown/internal/dropbox"
+	"github.com/mlesniak/markdown/internal/mentions"
+	"github.com/ziflex/lecho/v2"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runReport crawls all public notes like the server does on startup and
+// prints the requested report, e.g.
+//
+//	server report mentions
+func runReport(args []string) {
+	if len(args) < 1 || (args[0] != "mentions" && args[0] != "unpublished" && args[0] != "graph") {
+		fmt.Fprintln(os.Stderr, "Usage: server report mentions|unpublished|graph")
+		os.Exit(1)
+	}
+
+	// Only warnings are logged, on stderr, so the report is readable.
+	logger := lecho.New(os.Stderr, lecho.WithLevel(log.WARN), lecho.WithTimestamp())
+	dropboxService := initializeDropbox(logger)
+	initializeTheme(logger)
+	dropboxService.UpdateCache(rootFiles)
+
+	switch args[0] {
+	case "mentions":
+		printMentions(os.Stdout)
+	case "unpublished":
+		printUnpublished(os.Stdout, dropboxService)
+	case "graph":
+		printGraph(os.Stdout, dropboxService)
+	}
+}
+
+// printMentions prints all unlinked mentions, grouped by mentioned note.
+func printMentions(w io.Writer) {
+	target := ""
+	for _, mention := range mentions.Get().List() {
+		if mention.Target != target {
+			target = mention.Target
+			fmt.Fprintf(w, "%s\n", target)
+		}
+		context := strings.Join(strings.Fields(mention.Context), " ")
+		fmt.Fprintf(w, "\t%s: %s\n", mention.Source, context)
+	}
+}
+
+// printUnpublished prints all links to unpublished or missing notes, by source.
+func printUnpublished(w io.Writer, dropboxService *dropbox.Service) {
+	links := dropboxService.UnpublishedLinks()
+	sources := []string{}
+	for source := range links {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		fmt.Fprintf(w, "%s\n", source)
+		for _, link := range links[source] {
+			fmt.Fprintf(w, "\t%s\n", link)
+		}
+	}
+}
+
+// printGraph prints orphans, dead ends, hubs and unreachable public notes.
+func printGraph(w io.Writer, dropboxService *dropbox.Service) {
+	r := dropboxService.Report(rootFiles)
+
+	printSection(w, "Orphans", r.Orphans)
+	printSection(w, "Dead ends", r.DeadEnds)
+	fmt.Fprintf(w, "Hubs\n")
+	for _, hub := range r.Hubs {
+		fmt.Fprintf(w, "\t%d\t%s\n", hub.Incoming, hub.Note)
+	}
+	printSection(w, "Unreachable", r.Unreachable)
+}
+
+func printSection(w io.Writer, title string, notes []string) {
+	fmt.Fprintf(w, "%s\n", title)
+	for _, note := range notes {
+		fmt.Fprintf(w, "\t%s\n", note)
+	}
+}
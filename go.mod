@@ -1,8 +1,9 @@
 module github.com/mlesniak/markdown
 
-go 1.13
+go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/labstack/echo/v4 v4.1.15
 	github.com/labstack/gommon v0.3.0
 	github.com/rs/zerolog v1.15.0
@@ -1,43 +1,214 @@
 package backlinks
 
-type parent = map[string]struct{}
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"sync"
+)
 
-type Backlinks struct {
-	// Link from parent to list of files which are referencing it.
-	links map[string]parent
+// Graph contains all links between notes. It is built during a cache update
+// and safe for concurrent readers.
+type Graph struct {
+	// Links by source and by target.
+	outgoing map[string][]Occurrence
+	incoming map[string][]Occurrence
+	// All notes, including those without any links.
+	notes map[string]struct{}
+	lock  sync.RWMutex
 }
 
-var singleton *Backlinks
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{
+		outgoing: make(map[string][]Occurrence),
+		incoming: make(map[string][]Occurrence),
+		notes:    make(map[string]struct{}),
+	}
+}
+
+// AddNote adds a note and replaces all links originating from it.
+func (g *Graph) AddNote(filename string, occurrences []Occurrence) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	// Remove previous links of this note.
+	for _, occurrence := range g.outgoing[filename] {
+		kept := []Occurrence{}
+		for _, incoming := range g.incoming[occurrence.Target] {
+			if incoming.Source != filename {
+				kept = append(kept, incoming)
+			}
+		}
+		g.incoming[occurrence.Target] = kept
+	}
+
+	g.notes[filename] = struct{}{}
+	g.outgoing[filename] = occurrences
+	for _, occurrence := range occurrences {
+		g.incoming[occurrence.Target] = append(g.incoming[occurrence.Target], occurrence)
+	}
+}
+
+// Notes returns all notes, sorted.
+func (g *Graph) Notes() []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
 
-func init() {
-	singleton = &Backlinks{
-		links: make(map[string]parent),
+	notes := []string{}
+	for note := range g.notes {
+		notes = append(notes, note)
 	}
+	sort.Strings(notes)
+	return notes
 }
 
-func Get() *Backlinks {
-	return singleton
+// Outgoing returns all links in the note.
+func (g *Graph) Outgoing(filename string) []Occurrence {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return append([]Occurrence{}, g.outgoing[filename]...)
 }
 
-func (t *Backlinks) GetParents(filename string) []string {
-	mapFilenames, found := t.links[filename]
-	if !found {
-		return []string{}
+// Incoming returns all links referencing the note.
+func (g *Graph) Incoming(filename string) []Occurrence {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return append([]Occurrence{}, g.incoming[filename]...)
+}
+
+// Parents returns all notes linking to the note, sorted.
+func (g *Graph) Parents(filename string) []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return unique(g.incoming[filename], func(o Occurrence) string { return o.Source })
+}
+
+// Children returns all notes the note links to, sorted.
+func (g *Graph) Children(filename string) []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return unique(g.outgoing[filename], func(o Occurrence) string { return o.Target })
+}
+
+// LinkCount returns the number of distinct notes linking to the note and
+// being linked from the note.
+func (g *Graph) LinkCount(filename string) (int, int) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	parents := unique(g.incoming[filename], func(o Occurrence) string { return o.Source })
+	children := unique(g.outgoing[filename], func(o Occurrence) string { return o.Target })
+	return len(parents), len(children)
+}
+
+// ShortestPath returns the notes on the shortest path following links from
+// one note to another, including both. It returns nil if there is no path.
+func (g *Graph) ShortestPath(from string, to string) []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if _, found := g.notes[from]; !found {
+		return nil
 	}
 
-	filenames := []string{}
-	for k, _ := range mapFilenames {
-		filenames = append(filenames, k)
+	// Breadth-first search, remembering the predecessor of each note.
+	predecessors := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			path := []string{}
+			for note := to; note != ""; note = predecessors[note] {
+				path = append([]string{note}, path...)
+			}
+			return path
+		}
+
+		for _, child := range unique(g.outgoing[current], func(o Occurrence) string { return o.Target }) {
+			if _, visited := predecessors[child]; visited {
+				continue
+			}
+			predecessors[child] = current
+			queue = append(queue, child)
+		}
+	}
+	return nil
+}
+
+// Orphans returns all notes without incoming links, sorted.
+func (g *Graph) Orphans() []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	orphans := []string{}
+	for note := range g.notes {
+		if len(g.incoming[note]) == 0 {
+			orphans = append(orphans, note)
+		}
 	}
-	return filenames
+	sort.Strings(orphans)
+	return orphans
 }
 
-func (t *Backlinks) AddChildren(filename string, targets []string) {
-	for _, name := range targets {
-		// Race condition?
-		if t.links[name] == nil {
-			t.links[name] = make(map[string]struct{})
+// unique returns the sorted, distinct keys of all occurrences.
+func unique(occurrences []Occurrence, key func(Occurrence) string) []string {
+	seen := make(map[string]struct{})
+	keys := []string{}
+	for _, occurrence := range occurrences {
+		k := key(occurrence)
+		if _, found := seen[k]; found {
+			continue
 		}
-		t.links[name][filename] = struct{}{}
+		seen[k] = struct{}{}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// persistedGraph is the gob representation of a graph.
+type persistedGraph struct {
+	Outgoing map[string][]Occurrence
+	Incoming map[string][]Occurrence
+	Notes    []string
+}
+
+// GobEncode implements gob.GobEncoder, so that the graph can be persisted.
+func (g *Graph) GobEncode() ([]byte, error) {
+	notes := g.Notes()
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(persistedGraph{
+		Outgoing: g.outgoing,
+		Incoming: g.incoming,
+		Notes:    notes,
+	})
+	return buffer.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (g *Graph) GobDecode(data []byte) error {
+	var persisted persistedGraph
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&persisted); err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.outgoing = make(map[string][]Occurrence)
+	g.incoming = make(map[string][]Occurrence)
+	g.notes = make(map[string]struct{})
+	for filename, occurrences := range persisted.Outgoing {
+		g.outgoing[filename] = occurrences
+	}
+	for filename, occurrences := range persisted.Incoming {
+		g.incoming[filename] = occurrences
+	}
+	for _, note := range persisted.Notes {
+		g.notes[note] = struct{}{}
 	}
+	return nil
 }
@@ -0,0 +1,70 @@
+package backlinks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func link(source string, target string) Occurrence {
+	return Occurrence{Source: source, Target: target}
+}
+
+func TestGraph(t *testing.T) {
+	g := NewGraph()
+	g.AddNote("a.md", []Occurrence{link("a.md", "b.md"), link("a.md", "b.md")})
+	g.AddNote("b.md", []Occurrence{link("b.md", "c.md")})
+	g.AddNote("c.md", nil)
+
+	if parents, children := g.LinkCount("b.md"); parents != 1 || children != 1 {
+		t.Errorf("LinkCount(b.md) = %d, %d, want 1, 1", parents, children)
+	}
+	if path := g.ShortestPath("a.md", "c.md"); fmt.Sprint(path) != "[a.md b.md c.md]" {
+		t.Errorf("ShortestPath(a.md, c.md) = %v", path)
+	}
+	if orphans := g.Orphans(); fmt.Sprint(orphans) != "[a.md]" {
+		t.Errorf("Orphans() = %v", orphans)
+	}
+
+	// Replacing a note removes its previous links.
+	g.AddNote("a.md", []Occurrence{link("a.md", "c.md")})
+	if incoming := g.Incoming("b.md"); len(incoming) != 0 {
+		t.Errorf("Incoming(b.md) = %v, want none", incoming)
+	}
+	if parents := g.Parents("c.md"); fmt.Sprint(parents) != "[a.md b.md]" {
+		t.Errorf("Parents(c.md) = %v", parents)
+	}
+}
+
+// TestGraphConcurrency is meant to be run with -race.
+func TestGraphConcurrency(t *testing.T) {
+	g := NewGraph()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				source := fmt.Sprintf("%d.md", j%10)
+				target := fmt.Sprintf("%d.md", (j+i)%10)
+				g.AddNote(source, []Occurrence{link(source, target)})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				note := fmt.Sprintf("%d.md", j%10)
+				g.Outgoing(note)
+				g.Incoming(note)
+				g.LinkCount(note)
+				g.ShortestPath("0.md", note)
+				g.Orphans()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if notes := g.Notes(); len(notes) != 10 {
+		t.Errorf("Notes() = %v, want 10 notes", notes)
+	}
+}
@@ -5,20 +5,82 @@ import (
 	"strings"
 )
 
+const (
+	// Paragraphs longer than this are shortened to the sentence containing a link.
+	maxContextLength = 300
+)
+
+// Occurrence describes a single wiki link in a file.
+type Occurrence struct {
+	// Source is the file containing the link.
+	Source string
+	// Target is the linked file.
+	Target string
+	// Position is the byte offset of the link in the source.
+	Position int
+	// Context is the paragraph or sentence surrounding the link.
+	Context string
+}
+
 func GetLinks(data []byte) []string {
+	links := []string{}
+	for _, occurrence := range GetOccurrences("", data) {
+		links = append(links, occurrence.Target)
+	}
+	return links
+}
+
+// GetOccurrences returns all wiki links in the file together with their context.
+func GetOccurrences(source string, data []byte) []Occurrence {
 	markdown := string(data)
 	regex := regexp.MustCompile(`\[\[(.*?)\]\]`)
 
-	links := []string{}
+	occurrences := []Occurrence{}
 
-	submatches := regex.FindAllStringSubmatch(markdown, -1)
-	for _, matches := range submatches {
-		link := matches[1]
+	indices := regex.FindAllStringSubmatchIndex(markdown, -1)
+	for _, index := range indices {
+		link := markdown[index[2]:index[3]]
 		if !strings.HasSuffix(link, ".md") {
 			link = link + ".md"
 		}
-		links = append(links, link)
+		occurrences = append(occurrences, Occurrence{
+			Source:   source,
+			Target:   link,
+			Position: index[0],
+			Context:  Context(markdown, index[0], index[1]),
+		})
 	}
 
-	return links
+	return occurrences
+}
+
+// Context returns the paragraph around a link, or the sentence around the
+// link for long paragraphs.
+func Context(markdown string, start int, end int) string {
+	paragraphStart := strings.LastIndex(markdown[:start], "\n\n") + 1
+	paragraphEnd := strings.Index(markdown[end:], "\n\n")
+	if paragraphEnd < 0 {
+		paragraphEnd = len(markdown)
+	} else {
+		paragraphEnd += end
+	}
+	paragraph := markdown[paragraphStart:paragraphEnd]
+	if len(paragraph) <= maxContextLength {
+		return strings.TrimSpace(paragraph)
+	}
+
+	// Find the sentence containing the link.
+	sentenceStart := paragraphStart
+	for _, boundary := range []string{". ", "! ", "? ", "\n"} {
+		if i := strings.LastIndex(markdown[paragraphStart:start], boundary); i >= 0 && paragraphStart+i+len(boundary) > sentenceStart {
+			sentenceStart = paragraphStart + i + len(boundary)
+		}
+	}
+	sentenceEnd := paragraphEnd
+	for _, boundary := range []string{". ", "! ", "? ", "\n"} {
+		if i := strings.Index(markdown[end:paragraphEnd], boundary); i >= 0 && end+i+1 < sentenceEnd {
+			sentenceEnd = end + i + 1
+		}
+	}
+	return strings.TrimSpace(markdown[sentenceStart:sentenceEnd])
 }
@@ -1,18 +1,87 @@
 // A simple Cache abstraction. Is this actually feasible in Go or
 // a we over-engineering a simple map structure?
+//
+// Rendered pages are pinned, i.e. kept until they are replaced. Evictable
+// entries, e.g. media fetched on demand, are evicted in least recently used
+// order once the cache exceeds its byte budget.
 package cache
 
-import "sync"
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// Default byte budget, see SetBudget.
+	defaultBudget = 64 << 20
+)
 
 // CacheEntry describes a Cache entry.
 type Entry struct {
 	Name string
 	Data []byte
+	// ETag is the strong entity tag of the data. It is computed when adding
+	// the entry, if not set.
+	ETag string
+	// ModTime is the time of the last modification. If not set, it is the
+	// time the data was added or changed, see AddEntry.
+	ModTime time.Time
+	// Gzip and Brotli contain the compressed data, if compressible. They are
+	// computed when adding the entry, see Compress.
+	Gzip   []byte
+	Brotli []byte
+	// Evictable entries are removed if the cache exceeds its budget.
+	Evictable bool
+}
+
+// size returns the number of bytes used by the entry.
+func (e Entry) size() int64 {
+	return int64(len(e.Data) + len(e.Gzip) + len(e.Brotli))
+}
+
+// ETag computes a strong entity tag from the hash of the data.
+func ETag(data []byte) string {
+	hash := sha1.Sum(data)
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+// Variants of a note besides its html, see VariantName.
+const (
+	Markdown = "markdown"
+	JSON     = "json"
+)
+
+// VariantName returns the name of a variant of an entry, e.g. the markdown of
+// a note.
+func VariantName(name string, variant string) string {
+	return name + "#" + variant
+}
+
+// Stats describes the usage of the cache.
+type Stats struct {
+	Len       int   `json:"len"`
+	Size      int64 `json:"size"`
+	Budget    int64 `json:"budget"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
 }
 
 type Cache struct {
 	cache map[string]Entry
-	lock  sync.Mutex
+	// Names of evictable entries, the most recently used first.
+	lru      *list.List
+	elements map[string]*list.Element
+	// Names of pinned entries added since the last Prune.
+	fresh  map[string]struct{}
+	size   int64
+	budget int64
+	// Counters for Stats.
+	hits, misses, evictions int64
+	lock                    sync.Mutex
 }
 
 var once sync.Once
@@ -21,20 +90,149 @@ var singleton *Cache
 func Get() *Cache {
 	once.Do(func() {
 		singleton = &Cache{
-			cache: make(map[string]Entry),
+			cache:    make(map[string]Entry),
+			lru:      list.New(),
+			elements: make(map[string]*list.Element),
+			fresh:    make(map[string]struct{}),
+			budget:   defaultBudget,
 		}
 	})
 
 	return singleton
 }
 
-func (c *Cache) AddEntry(entry Entry) {
+// SetBudget sets the maximum number of bytes of all entries. Only evictable
+// entries are removed to stay within it, hence pinned entries can exceed it.
+func (c *Cache) SetBudget(budget int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.budget = budget
+	c.evict()
+}
+
+// AddEntry adds or replaces an entry and returns it with its computed fields,
+// e.g. the compressed data. Without ModTime, replacing an entry by the same
+// data keeps its modification time, otherwise it is the current time.
+func (c *Cache) AddEntry(entry Entry) Entry {
+	if entry.ETag == "" {
+		entry.ETag = ETag(entry.Data)
+	}
+	// Unchanged entries keep their compressed data, so only changed ones are
+	// compressed again.
+	c.lock.Lock()
+	previous, unchanged := c.cache[entry.Name]
+	c.lock.Unlock()
+	unchanged = unchanged && previous.ETag == entry.ETag
+	if unchanged {
+		entry.Gzip, entry.Brotli = previous.Gzip, previous.Brotli
+	} else {
+		Compress(&entry)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry.ModTime.IsZero() {
+		entry.ModTime = time.Now().UTC().Truncate(time.Second)
+		if unchanged && !previous.ModTime.IsZero() {
+			entry.ModTime = previous.ModTime
+		}
+	}
+	c.insert(entry)
+	if !entry.Evictable {
+		c.fresh[entry.Name] = struct{}{}
+	}
+	return entry
+}
+
+// Prune removes pinned entries which were not added since the last call, e.g.
+// pages of deleted notes after a cache update, and returns their number.
+// Restored entries count as not added.
+func (c *Cache) Prune() int {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	removed := 0
+	for name, entry := range c.cache {
+		if _, found := c.fresh[name]; !found && !entry.Evictable {
+			c.remove(name)
+			removed++
+		}
+	}
+	c.fresh = make(map[string]struct{})
+	return removed
+}
+
+// Restore adds entries as they are, e.g. when loading a persisted snapshot,
+// without computing their tags or compressing them again.
+func (c *Cache) Restore(entries []Entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, entry := range entries {
+		c.insert(entry)
+	}
+}
+
+// Entries returns a copy of all entries.
+func (c *Cache) Entries() []Entry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entries := make([]Entry, 0, len(c.cache))
+	for _, entry := range c.cache {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// insert adds or replaces an entry. The lock must be held.
+func (c *Cache) insert(entry Entry) {
+	c.remove(entry.Name)
+	if entry.Evictable && c.size+entry.size() > c.budget && entry.size() > c.budget-c.pinnedSize() {
+		// Would not fit even after evicting all other evictable entries.
+		return
+	}
 	c.cache[entry.Name] = entry
+	c.size += entry.size()
+	if entry.Evictable {
+		c.elements[entry.Name] = c.lru.PushFront(entry.Name)
+		c.evict()
+	}
+}
+
+// remove removes an entry, if it exists. The lock must be held.
+func (c *Cache) remove(name string) {
+	entry, ok := c.cache[name]
+	if !ok {
+		return
+	}
+	delete(c.cache, name)
+	c.size -= entry.size()
+	if element, ok := c.elements[name]; ok {
+		c.lru.Remove(element)
+		delete(c.elements, name)
+	}
+}
+
+// evict removes the least recently used evictable entries until the cache is
+// within its budget. The lock must be held.
+func (c *Cache) evict() {
+	for c.size > c.budget && c.lru.Len() > 0 {
+		c.remove(c.lru.Back().Value.(string))
+		c.evictions++
+	}
+}
+
+// pinnedSize returns the size of all entries which are not evictable. The lock
+// must be held.
+func (c *Cache) pinnedSize() int64 {
+	size := c.size
+	for name := range c.elements {
+		size -= c.cache[name].size()
+	}
+	return size
 }
 
 func (c *Cache) List() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	keys := []string{}
 
 	for k := range c.cache {
@@ -43,12 +241,55 @@ func (c *Cache) List() []string {
 	return keys
 }
 
-func (c *Cache) GetEntry(name string) ([]byte, bool) {
+// Get returns the entry including its metadata.
+func (c *Cache) Get(name string) (Entry, bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	entry, ok := c.cache[name]
+	if !ok {
+		c.misses++
+		return Entry{}, false
+	}
+	c.hits++
+	if element, ok := c.elements[name]; ok {
+		c.lru.MoveToFront(element)
+	}
+	return entry, true
+}
+
+func (c *Cache) GetEntry(name string) ([]byte, bool) {
+	entry, ok := c.Get(name)
 	if !ok {
 		return nil, false
 	}
 	return entry.Data, true
 }
+
+// Len returns the number of entries.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.cache)
+}
+
+// Size returns the number of bytes of all entries, including their
+// compressed data.
+func (c *Cache) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.size
+}
+
+// Stats returns the usage of the cache.
+func (c *Cache) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return Stats{
+		Len:       len(c.cache),
+		Size:      c.size,
+		Budget:    c.budget,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"path"
+	"strings"
+)
+
+const (
+	// Smaller entries are not compressed, since the savings are negligible.
+	minCompressSize = 256
+
+	// Moderate levels, since the best ones are several times slower for
+	// hardly smaller pages.
+	gzipLevel   = gzip.DefaultCompression
+	brotliLevel = 5
+)
+
+// Formats which are already compressed.
+var compressed = map[string]struct{}{
+	".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".webp": {}, ".ico": {}, ".gz": {}, ".br": {}, ".zip": {},
+	".pdf": {}, ".mp3": {}, ".m4a": {}, ".ogg": {}, ".mp4": {}, ".webm": {}, ".woff": {}, ".woff2": {},
+}
+
+// Compress compresses the data of the entry with gzip and brotli, unless it is
+// small or already compressed. Compressed data is only kept if it is smaller.
+func Compress(entry *Entry) {
+	extension := strings.ToLower(path.Ext(entry.Name))
+	if _, found := compressed[extension]; found || len(entry.Data) < minCompressSize {
+		return
+	}
+
+	var gz bytes.Buffer
+	gzipWriter, _ := gzip.NewWriterLevel(&gz, gzipLevel)
+	if _, err := gzipWriter.Write(entry.Data); err == nil && gzipWriter.Close() == nil && gz.Len() < len(entry.Data) {
+		entry.Gzip = gz.Bytes()
+	}
+
+	var br bytes.Buffer
+	brotliWriter := brotli.NewWriterLevel(&br, brotliLevel)
+	if _, err := brotliWriter.Write(entry.Data); err == nil && brotliWriter.Close() == nil && br.Len() < len(entry.Data) {
+		entry.Brotli = br.Bytes()
+	}
+}
@@ -10,22 +10,24 @@ import (
 	"time"
 )
 
-// apiCallHeader generalizes different api calls to dropbox.
+// apiCallHeader generalizes different api calls to dropbox. Besides the body it
+// returns the value of the Dropbox-API-Result header which contains the
+// metadata of downloaded files.
 //
 // Will later be non-public again after Refactoring.
-func (s *Service) apiCallHeader(url string, argument interface{}) ([]byte, error) {
+func (s *Service) apiCallHeader(url string, argument interface{}) ([]byte, string, error) {
 	// Create general request.
 	client := http.Client{}
 	client.Timeout = time.Second * 10
 	request, err := http.NewRequest("POST", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %s", err)
+		return nil, "", fmt.Errorf("unable to create request: %s", err)
 	}
 
 	// Create payload.
 	rawJson, err := json.Marshal(argument)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create payload: %s", err)
+		return nil, "", fmt.Errorf("unable to create payload: %s", err)
 	}
 
 	// Set token and payload for submitting.
@@ -36,21 +38,21 @@ func (s *Service) apiCallHeader(url string, argument interface{}) ([]byte, error
 	s.Log.Infof("Performing dropbox API call to %s with payload=%v", url, argument)
 	resp, err := client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %s", err)
+		return nil, "", fmt.Errorf("unable to perform request: %s", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response.
 	bs, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read data from response: %s", err)
+		return nil, "", fmt.Errorf("unable to read data from response: %s", err)
 	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("non 200 response from dropbox: `%s`", string(bs))
+		return nil, "", fmt.Errorf("non 200 response from dropbox: `%s`", string(bs))
 	}
 
 	// Return data and log elapsed time.
-	return bs, err
+	return bs, resp.Header.Get("Dropbox-API-Result"), err
 }
 
 // Consistency is not dropbox's strength, although I understand the idea behind this :-/
@@ -1,14 +1,20 @@
 package dropbox
 
 import (
-	"bytes"
+	"encoding/json"
 	"github.com/labstack/echo/v4"
 	"github.com/mlesniak/markdown/internal/backlinks"
 	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/feed"
 	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/mentions"
+	"github.com/mlesniak/markdown/internal/routes"
+	"github.com/mlesniak/markdown/internal/site"
 	"github.com/mlesniak/markdown/internal/tags"
+	"github.com/mlesniak/markdown/internal/templates"
 	"github.com/mlesniak/markdown/internal/utils"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,8 +28,42 @@ type Service struct {
 	RootDirectory string
 	Log           echo.Logger
 
+	// BaseURL is the absolute URL of the site, e.g. https://mlesniak.com, and
+	// used whenever we need absolute links, e.g. in feeds.
+	BaseURL string
+
+	// RobotsDisallow lists the paths robots.txt forbids crawlers to visit.
+	RobotsDisallow []string
+
+	// MentionOptions control which titles are considered for unlinked mentions.
+	MentionOptions mentions.Options
+
+	// UnpublishedLinkText replaces links to unpublished or missing notes.
+	UnpublishedLinkText string
+	// SlugMode determines the canonical paths of notes.
+	SlugMode routes.Mode
+	// Redirects maps old paths to their new path, see routes.New.
+	Redirects map[string]string
+	// MediaCacheLimit is the maximum size of media files kept in the cache.
+	// Larger files are streamed from dropbox on every request.
+	MediaCacheLimit int64
+	// SnapshotDirectory stores the last snapshot of the site, if set, see
+	// SaveSnapshot.
+	SnapshotDirectory string
+
 	// Since we have only one account, the cursor is part of the service.
 	cursor string
+
+	// Revisions of all published notes of the last cache update.
+	revisions map[string]Metadata
+	// Whether files are public, by filename, see rememberPublic.
+	publicFiles map[string]publicFile
+	// Cache updates are started by the webhook and at startup and must not
+	// run concurrently.
+	updating *sync.Mutex
+
+	// Links to unpublished or missing notes of the last cache update, by source.
+	unpublishedLinks map[string][]string
 }
 
 // Get returns a new dropbox service.
@@ -31,22 +71,73 @@ func New(s Service) *Service {
 	if !strings.HasSuffix(s.RootDirectory, "/") {
 		panic("rootDirectory without / suffix:" + s.RootDirectory)
 	}
+	s.BaseURL = strings.TrimSuffix(s.BaseURL, "/")
+	s.updating = &sync.Mutex{}
+	s.publicFiles = make(map[string]publicFile)
 
 	return &s
 }
 
 func (s *Service) UpdateCache(filenames []string) {
+	if len(filenames) == 0 {
+		s.Log.Warnf("No root files, not updating the cache")
+		return
+	}
+	s.updating.Lock()
+	defer s.updating.Unlock()
 	now := time.Now()
 
-	fileBuffers := s.loadFiles(filenames)
-	tags := s.processFiles(fileBuffers)
-	s.generateTagPages(tags)
+	snapshot := site.New()
+	fileBuffers, metadata := s.loadFiles(filenames)
+	// An empty crawl, e.g. if dropbox is unavailable, must not replace the
+	// last good site and its snapshot.
+	if len(fileBuffers) == 0 {
+		s.Log.Warnf("No published notes found, keeping the current site")
+		return
+	}
+	if s.revisions != nil {
+		s.Log.Infof("Changed notes since last update. count=%d", changedNotes(s.revisions, metadata))
+	}
+	tagIndex, items := s.processFiles(snapshot, filenames[0], fileBuffers, metadata)
+	s.generateFeeds(tagIndex.Map(), items)
+	s.generateSitemap(snapshot, fileBuffers, metadata, tagIndex.Map())
+	s.generateGraph(snapshot, fileBuffers, tagIndex)
+	s.findUnreachable(snapshot)
+	site.Set(snapshot)
+	if removed := cache.Get().Prune(); removed > 0 {
+		s.Log.Infof("Removed stale cache entries. count=%d", removed)
+	}
+
+	s.revisions = metadata
+
+	stats := cache.Get().Stats()
+	s.Log.Infof("Cache update took %dms. entries=%d, size=%d", time.Now().Sub(now).Milliseconds(), stats.Len, stats.Size)
 
-	s.Log.Infof("Cache update took %dms", time.Now().Sub(now).Milliseconds())
+	if err := s.SaveSnapshot(); err != nil {
+		s.Log.Warnf("Unable to save snapshot. error=%s", err.Error())
+	}
+}
+
+// changedNotes returns the number of notes which were added, removed or
+// modified between two cache updates.
+func changedNotes(previous map[string]Metadata, current map[string]Metadata) int {
+	changed := 0
+	for filename, md := range current {
+		if old, ok := previous[filename]; !ok || old.Rev != md.Rev || !old.ServerModified.Equal(md.ServerModified) {
+			changed++
+		}
+	}
+	for filename := range previous {
+		if _, ok := current[filename]; !ok {
+			changed++
+		}
+	}
+	return changed
 }
 
-func (s *Service) loadFiles(filenames []string) map[string][]byte {
+func (s *Service) loadFiles(filenames []string) (map[string][]byte, map[string]Metadata) {
 	fileBuffers := make(map[string][]byte)
+	metadata := make(map[string]Metadata)
 	visitedFiles := make(map[string]struct{})
 	queue := filenames
 	for len(queue) > 0 {
@@ -59,11 +150,12 @@ func (s *Service) loadFiles(filenames []string) map[string][]byte {
 		visitedFiles[filename] = struct{}{}
 
 		s.Log.Infof("Reading file. filename=%s", filename)
-		bs, err := s.Read(filename)
+		bs, md, err := s.ReadWithMetadata(filename)
 		if err != nil {
 			s.Log.Infof("File found found. filename=%s", filename)
 			continue
 		}
+		s.rememberPublic(filename, md, bs)
 
 		if !isPublic(bs) {
 			s.Log.Warnf("Preventing caching of non-public file. filename=%s", filename)
@@ -74,56 +166,133 @@ func (s *Service) loadFiles(filenames []string) map[string][]byte {
 		queue = append(queue, links...)
 
 		fileBuffers[filename] = bs
+		metadata[filename] = md
 	}
 	s.Log.Infof("Queued: %d files", len(fileBuffers))
-	return fileBuffers
+	return fileBuffers, metadata
 }
 
-func (s *Service) generateTagPages(tagMap map[string][]string) {
-	for tag, filenames := range tagMap {
-		tagName := "tag-" + tag[1:] + ".md"
-		s.Log.Infof("Adding tag to cache. filename=%s", tagName)
-		bs := tags.GenerateTagPage(s.Log, tag, filenames)
-		cache.Get().AddEntry(cache.Entry{
-			Name: tagName,
-			Data: bs,
-		})
+// addVariants adds the public markdown and the JSON representation of a note
+// to the cache. Like the html, they depend on other notes, e.g. on backlinks,
+// hence their modification time is left to the cache instead of being taken
+// from the note.
+func (s *Service) addVariants(renderer markdown.Renderer, filename string, bs []byte, page templates.Page, modified time.Time) {
+	cache.Get().AddEntry(cache.Entry{
+		Name: cache.VariantName(filename, cache.Markdown),
+		Data: renderer.PublicMarkdown(bs),
+	})
+
+	document, err := json.Marshal(renderer.ToDocument(filename, page, modified))
+	if err != nil {
+		s.Log.Warnf("Unable to convert file to JSON. filename=%s, error=%s", filename, err.Error())
+		return
 	}
+	cache.Get().AddEntry(cache.Entry{
+		Name: cache.VariantName(filename, cache.JSON),
+		Data: document,
+	})
 }
 
-func (s *Service) processFiles(fileBuffers map[string][]byte) map[string][]string {
+// processFiles renders all files and returns the index of all tags and
+// the feed items of all files with a publication date. The index file is
+// rendered with its own template. Links between files, tags and summaries of
+// all files for tag pages are added to the snapshot.
+func (s *Service) processFiles(snapshot *site.Snapshot, index string, fileBuffers map[string][]byte, metadata map[string]Metadata) (*tags.Index, map[string]feed.Item) {
+	for filename, bs := range fileBuffers {
+		// Links to unpublished notes are not part of the graph.
+		occurrences := []backlinks.Occurrence{}
+		for _, occurrence := range backlinks.GetOccurrences(filename, bs) {
+			if _, published := fileBuffers[occurrence.Target]; published {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+		snapshot.Links.AddNote(filename, occurrences)
+		s.Log.Infof("Adding links. filename=%s, links=%d", filename, len(occurrences))
+	}
+	snapshot.Routes = routes.New(s.SlugMode, fileBuffers, s.Redirects)
+	mentions.Get().Update(fileBuffers, s.MentionOptions)
+	s.checkUnpublishedLinks(fileBuffers)
+
+	// All tags are necessary for the tag cloud before rendering.
+	tagIndex := tags.NewIndex()
 	for filename, bs := range fileBuffers {
-		bls := backlinks.GetLinks(bs)
-		backlinks.Get().AddChildren(filename, bls)
-		s.Log.Infof("Adding children. filename=%s, children=%v", filename, bls)
+		tagIndex.Add(filename, utils.GetTags(bs))
+	}
+	snapshot.Tags = tagIndex
+
+	renderer := markdown.Renderer{
+		BaseURL:             s.BaseURL,
+		Published:           make(map[string]struct{}),
+		Links:               snapshot.Links,
+		Routes:              snapshot.Routes,
+		UnpublishedLinkText: s.UnpublishedLinkText,
+	}
+	for filename := range fileBuffers {
+		renderer.Published[filename] = struct{}{}
 	}
 
-	tagMap := make(map[string][]string)
+	items := make(map[string]feed.Item)
 	for filename, bs := range fileBuffers {
-		ts := utils.GetTags(bs)
-		for _, t := range ts {
-			_, found := tagMap[t]
-			if !found {
-				tagMap[t] = []string{filename}
-			} else {
-				// Does this work on empty, too?
-				tagMap[t] = append(tagMap[t], filename)
-			}
+		page := renderer.ToPage(filename, bs)
+		kind := templates.Note
+		if filename == index {
+			kind = templates.Index
+		}
+		html, err := templates.Get().Render(kind, page)
+		if err != nil {
+			s.Log.Warnf("Unable to render file. filename=%s, error=%s", filename, err.Error())
+			continue
 		}
-
-		html, _ := markdown.ToHTML(s.Log, filename, bs)
 		s.Log.Infof("Adding cache entry. filename=%s", filename)
 		cache.Get().AddEntry(cache.Entry{
 			Name: filename,
-			Data: []byte(html),
+			Data: html,
 		})
+		s.addVariants(renderer, filename, bs, page, lastModified(bs, metadata[filename]))
+		snapshot.Notes[filename] = templates.Link{
+			Title:       page.Title,
+			Path:        snapshot.Routes.Path(filename),
+			Date:        page.Date,
+			Description: page.Description,
+		}
+
+		if page.Date.IsZero() {
+			s.Log.Infof("No publication date, ignoring for feeds. filename=%s", filename)
+			continue
+		}
+		items[filename] = feed.Item{
+			Title:   page.Title,
+			Path:    snapshot.Routes.Path(filename),
+			Date:    page.Date,
+			Content: string(page.Content),
+		}
+	}
+	return tagIndex, items
+}
+
+// checkUnpublishedLinks reports all links to notes which are not published,
+// either since they are not public or do not exist.
+func (s *Service) checkUnpublishedLinks(fileBuffers map[string][]byte) {
+	s.unpublishedLinks = make(map[string][]string)
+	for filename, bs := range fileBuffers {
+		for _, link := range backlinks.GetLinks(bs) {
+			if _, published := fileBuffers[link]; published {
+				continue
+			}
+			s.Log.Warnf("Link to unpublished note. filename=%s, link=%s", filename, link)
+			s.unpublishedLinks[filename] = append(s.unpublishedLinks[filename], link)
+		}
 	}
-	return tagMap
+}
+
+// UnpublishedLinks returns all links to unpublished or missing notes of the
+// last cache update, by source.
+func (s *Service) UnpublishedLinks() map[string][]string {
+	return s.unpublishedLinks
 }
 
 // isPublic checks if a file is allowed to be displayed by enforcing
-// the existence of a text string in each file.
+// the existence of the #public tag in each file. Drafts are never displayed.
 func isPublic(bs []byte) bool {
-	publicTag := "#public"
-	return bytes.Contains(bs, []byte(publicTag))
+	return utils.HasTag(bs, utils.PublicTag) && !utils.HasTag(bs, utils.DraftTag)
 }
@@ -0,0 +1,68 @@
+package dropbox
+
+import (
+	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/feed"
+	"github.com/mlesniak/markdown/internal/utils"
+)
+
+// Title of all generated feeds.
+const feedTitle = "mlesniak.com"
+
+// generateFeeds stores Atom, RSS and JSON feeds for all notes and an Atom feed
+// for each tag in the cache.
+func (s *Service) generateFeeds(tagMap map[string][]string, items map[string]feed.Item) {
+	all := feed.Feed{
+		Title:   feedTitle,
+		BaseURL: s.BaseURL,
+	}
+	for _, item := range items {
+		all.Items = append(all.Items, item)
+	}
+	all.SortItems()
+
+	generators := map[string]func(feed.Feed) ([]byte, error){
+		"feed.xml":  feed.Atom,
+		"rss.xml":   feed.RSS,
+		"feed.json": feed.JSON,
+	}
+	for name, generator := range generators {
+		all.Path = "/" + name
+		s.addFeed(name, generator, all)
+	}
+
+	for tag, filenames := range tagMap {
+		name := utils.TagFilename(tag, ".xml")
+		tagFeed := feed.Feed{
+			Title:   feedTitle + " - " + tag,
+			BaseURL: s.BaseURL,
+			Path:    "/" + name,
+		}
+		// A file contains the same tag multiple times if it's used more than once.
+		seen := make(map[string]struct{})
+		for _, filename := range filenames {
+			if _, found := seen[filename]; found {
+				continue
+			}
+			seen[filename] = struct{}{}
+			if item, ok := items[filename]; ok {
+				tagFeed.Items = append(tagFeed.Items, item)
+			}
+		}
+		tagFeed.SortItems()
+		s.addFeed(name, feed.Atom, tagFeed)
+	}
+}
+
+func (s *Service) addFeed(name string, generator func(feed.Feed) ([]byte, error), f feed.Feed) {
+	bs, err := generator(f)
+	if err != nil {
+		s.Log.Warnf("Unable to generate feed. filename=%s, error=%s", name, err.Error())
+		return
+	}
+	s.Log.Infof("Adding feed to cache. filename=%s, items=%d", name, len(f.Items))
+	cache.Get().AddEntry(cache.Entry{
+		Name: name,
+		Data: bs,
+	})
+}
@@ -0,0 +1,53 @@
+package dropbox
+
+import (
+	"github.com/mlesniak/markdown/internal/graph"
+	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/tags"
+	"github.com/mlesniak/markdown/internal/utils"
+	"time"
+)
+
+// generateGraph computes the graph of all published notes and their tags.
+// Since only published notes become nodes, links to private notes are dropped.
+func (s *Service) generateGraph(snapshot *site.Snapshot, fileBuffers map[string][]byte, tagIndex *tags.Index) {
+	nodes := []graph.Node{}
+	edges := []graph.Edge{}
+	for filename, bs := range fileBuffers {
+		node := graph.Node{
+			ID:    filename,
+			Kind:  graph.NoteNode,
+			Title: markdown.VisibleLink(filename),
+			Path:  snapshot.Routes.Path(filename),
+		}
+		frontMatter, _ := utils.FrontMatter(bs)
+		if date, ok := utils.NoteDate(filename, frontMatter); ok {
+			node.Date = date.Format(time.RFC3339)
+		}
+		nodes = append(nodes, node)
+
+		for _, link := range snapshot.Links.Children(filename) {
+			edges = append(edges, graph.Edge{Source: filename, Target: link, Kind: graph.LinkEdge})
+		}
+		for _, tag := range utils.GetTags(bs) {
+			edges = append(edges, graph.Edge{Source: filename, Target: tag, Kind: graph.TagEdge})
+		}
+	}
+
+	// Hierarchical tags point to their parent, e.g. #lang/go to #lang.
+	for _, tag := range tagIndex.Tags() {
+		nodes = append(nodes, graph.Node{
+			ID:    tag,
+			Kind:  graph.TagNode,
+			Title: tag,
+			Path:  utils.PagePath(utils.TagFilename(tag, ".md")),
+		})
+		for _, child := range tagIndex.Children(tag) {
+			edges = append(edges, graph.Edge{Source: child, Target: tag, Kind: graph.TagEdge})
+		}
+	}
+
+	snapshot.Graph = graph.New(nodes, edges)
+	s.Log.Infof("Updating graph. nodes=%d, edges=%d", len(snapshot.Graph.Nodes), len(snapshot.Graph.Edges))
+}
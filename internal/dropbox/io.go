@@ -1,11 +1,30 @@
 package dropbox
 
 import (
+	"encoding/json"
+	"github.com/mlesniak/markdown/internal/utils"
 	"io/ioutil"
 	"os"
 	"time"
 )
 
+// Metadata contains the information dropbox returns alongside a downloaded file.
+type Metadata struct {
+	ServerModified time.Time `json:"server_modified"`
+	Rev            string    `json:"rev"`
+	Size           int64     `json:"size"`
+}
+
+// lastModified returns the last modification of a file, taken from its front
+// matter (lastmod) or, if not available, from dropbox.
+func lastModified(bs []byte, md Metadata) time.Time {
+	frontMatter, _ := utils.FrontMatter(bs)
+	if lastMod, ok := utils.ParseDate(frontMatter["lastmod"]); ok {
+		return lastMod
+	}
+	return md.ServerModified
+}
+
 // Read downloads the requested file from dropbox.
 //
 // I'm still not happy that the echo logger interface is polluting our
@@ -14,11 +33,26 @@ import (
 // zerolog, but this is a lot of work for this small program, hence 🤷‍.
 // Although I miss zerlog's context, e.g. for filenames.
 func (s *Service) Read(filename string) ([]byte, error) {
+	bs, _, err := s.ReadWithMetadata(filename)
+	return bs, err
+}
+
+// ReadWithMetadata downloads the requested file from dropbox and returns its
+// metadata, e.g. the modification time.
+func (s *Service) ReadWithMetadata(filename string) ([]byte, Metadata, error) {
 	// Ugly hack for local development.
 	if local := os.Getenv("LOCAL"); local != "" {
 		path := os.Getenv("HOME") + "/Dropbox/" + s.RootDirectory + "/" + filename
 		s.Log.Infof("Reading from local storage: %s -> %s", filename, path)
-		return ioutil.ReadFile(path)
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		return bs, Metadata{ServerModified: info.ModTime().UTC(), Size: info.Size()}, nil
 	}
 
 	start := time.Now()
@@ -28,11 +62,16 @@ func (s *Service) Read(filename string) ([]byte, error) {
 	}{
 		Path: "/" + s.RootDirectory + filename,
 	}
-	bs, err := s.apiCallHeader("https://content.dropboxapi.com/2/files/download", argument)
+	bs, result, err := s.apiCallHeader("https://content.dropboxapi.com/2/files/download", argument)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal([]byte(result), &metadata); err != nil {
+		s.Log.Warnf("Unable to parse file metadata. filename=%s, error=%s", filename, err.Error())
 	}
 
 	s.Log.Infof("Read file from dropbox. filename=%s, duration=%v", filename, time.Since(start).Milliseconds())
-	return bs, err
+	return bs, metadata, err
 }
@@ -0,0 +1,128 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// File is a markdown file in the root directory.
+type File struct {
+	Name string
+	// Revision changes whenever the file is modified, see Metadata.Revision.
+	Revision string
+}
+
+// ListFiles returns all markdown files in the root directory, sorted by name.
+func (s *Service) ListFiles() ([]File, error) {
+	// Ugly hack for local development.
+	if local := os.Getenv("LOCAL"); local != "" {
+		infos, err := ioutil.ReadDir(os.Getenv("HOME") + "/Dropbox/" + s.RootDirectory)
+		if err != nil {
+			return nil, err
+		}
+		files := []File{}
+		for _, info := range infos {
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
+				md := Metadata{ServerModified: info.ModTime().UTC(), Size: info.Size()}
+				files = append(files, File{Name: info.Name(), Revision: md.Revision()})
+			}
+		}
+		return files, nil
+	}
+
+	type listing struct {
+		Entries []entry `json:"entries"`
+		Cursor  string  `json:"cursor"`
+		HasMore bool    `json:"has_more"`
+	}
+
+	files := []File{}
+	url := "https://api.dropboxapi.com/2/files/list_folder"
+	var argument interface{} = struct {
+		Path string `json:"path"`
+	}{
+		Path: "/" + strings.TrimSuffix(s.RootDirectory, "/"),
+	}
+	for {
+		bs, err := s.apiCall(s.Log, url, argument)
+		if err != nil {
+			return nil, err
+		}
+		var l listing
+		if err := json.Unmarshal(bs, &l); err != nil {
+			return nil, fmt.Errorf("unable to parse listing: %s", err)
+		}
+		for _, e := range l.Entries {
+			if e.Tag == "file" && strings.HasSuffix(e.Name, ".md") {
+				files = append(files, File{Name: e.Name, Revision: e.Rev})
+			}
+		}
+		if !l.HasMore {
+			break
+		}
+
+		url = "https://api.dropboxapi.com/2/files/list_folder/continue"
+		argument = struct {
+			Cursor string `json:"cursor"`
+		}{
+			Cursor: l.Cursor,
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// UnreachablePublicNotes returns all public notes which are not published,
+// since they can not be reached by following links from the root files. Only
+// files which changed since they were last read are downloaded, see
+// rememberPublic.
+func (s *Service) UnreachablePublicNotes(published []string) ([]string, error) {
+	files, err := s.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]struct{})
+	for _, name := range published {
+		known[name] = struct{}{}
+	}
+
+	unreachable := []string{}
+	for _, file := range files {
+		if _, found := known[file.Name]; found {
+			continue
+		}
+		public, ok := s.publicFiles[file.Name]
+		if !ok || public.revision != file.Revision {
+			bs, md, err := s.ReadWithMetadata(file.Name)
+			if err != nil {
+				s.Log.Warnf("Unable to read file. filename=%s, error=%s", file.Name, err.Error())
+				continue
+			}
+			s.rememberPublic(file.Name, md, bs)
+			public = s.publicFiles[file.Name]
+		}
+		if public.public {
+			unreachable = append(unreachable, file.Name)
+		}
+	}
+	return unreachable, nil
+}
+
+// publicFile remembers whether a revision of a file is public.
+type publicFile struct {
+	revision string
+	public   bool
+}
+
+// rememberPublic stores whether a file is public, so that it is not
+// downloaded again to search for unreachable notes until it changes.
+func (s *Service) rememberPublic(filename string, md Metadata, bs []byte) {
+	s.publicFiles[filename] = publicFile{revision: md.Revision(), public: isPublic(bs)}
+}
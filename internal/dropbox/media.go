@@ -0,0 +1,157 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Media is an opened media file, which is read lazily. Seeking is supported
+// without downloading the skipped data, e.g. to serve range requests.
+type Media struct {
+	io.ReadSeeker
+	io.Closer
+	Metadata
+}
+
+// ETag returns the strong entity tag of the media file, based on its revision
+// or, if unknown, its modification time and size.
+func (m Metadata) ETag() string {
+	if m.Rev != "" {
+		return `"` + m.Rev + `"`
+	}
+	return fmt.Sprintf(`"%x-%x"`, m.ServerModified.UnixNano(), m.Size)
+}
+
+// Revision identifies the version of a file, i.e. its revision from dropbox
+// or, if unknown, its modification time and size.
+func (m Metadata) Revision() string {
+	return strings.Trim(m.ETag(), `"`)
+}
+
+// OpenMedia opens a file for streaming. Only its metadata is requested until
+// it is read.
+func (s *Service) OpenMedia(ctx context.Context, filename string) (Media, error) {
+	// Ugly hack for local development.
+	if local := os.Getenv("LOCAL"); local != "" {
+		path := os.Getenv("HOME") + "/Dropbox/" + s.RootDirectory + "/" + filename
+		file, err := os.Open(path)
+		if err != nil {
+			return Media{}, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return Media{}, err
+		}
+		return Media{file, file, Metadata{ServerModified: info.ModTime().UTC(), Size: info.Size()}}, nil
+	}
+
+	argument := struct {
+		Path string `json:"path"`
+	}{
+		Path: "/" + s.RootDirectory + filename,
+	}
+	bs, err := s.apiCall(s.Log, "https://api.dropboxapi.com/2/files/get_metadata", argument)
+	if err != nil {
+		return Media{}, err
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(bs, &metadata); err != nil {
+		return Media{}, fmt.Errorf("unable to parse metadata: %s", err)
+	}
+
+	file := &remoteFile{service: s, ctx: ctx, path: argument.Path, size: metadata.Size}
+	return Media{file, file, metadata}, nil
+}
+
+// remoteFile reads a file from dropbox starting at the current offset.
+type remoteFile struct {
+	service *Service
+	ctx     context.Context
+	path    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (f *remoteFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		body, err := f.download()
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if offset != f.offset {
+		f.Close()
+		f.offset = offset
+	}
+	return offset, nil
+}
+
+func (f *remoteFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+// download requests the file from the current offset on. There is no timeout,
+// since large files take long; the request is cancelled with its context.
+func (f *remoteFile) download() (io.ReadCloser, error) {
+	rawJson, err := json.Marshal(struct {
+		Path string `json:"path"`
+	}{f.path})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create payload: %s", err)
+	}
+	request, err := http.NewRequestWithContext(f.ctx, "POST", "https://content.dropboxapi.com/2/files/download", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %s", err)
+	}
+	request.Header.Add("Authorization", "Bearer "+f.service.Token)
+	request.Header.Add("Dropbox-API-Arg", string(rawJson))
+	request.Header.Add("Range", fmt.Sprintf("bytes=%d-", f.offset))
+
+	f.service.Log.Infof("Streaming file from dropbox. path=%s, offset=%d", f.path, f.offset)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %s", err)
+	}
+	// Without a partial response, the data would start at the beginning of the
+	// file instead of the offset.
+	if resp.StatusCode != http.StatusPartialContent && (resp.StatusCode != http.StatusOK || f.offset > 0) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response from dropbox: %d", resp.StatusCode)
+	}
+	f.service.Log.Infof("Started streaming from dropbox. path=%s, duration=%v", f.path, time.Since(start).Milliseconds())
+	return resp.Body, nil
+}
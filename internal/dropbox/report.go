@@ -0,0 +1,31 @@
+package dropbox
+
+import (
+	"github.com/mlesniak/markdown/internal/report"
+	"github.com/mlesniak/markdown/internal/site"
+)
+
+const (
+	// Number of hubs in reports.
+	reportHubs = 10
+)
+
+// Report analyzes the link graph of the last cache update. Unreachable public
+// notes are searched for during the cache update, see findUnreachable.
+func (s *Service) Report(rootFiles []string) report.Report {
+	current := site.Get()
+	r := report.Compute(current.Links, rootFiles, reportHubs)
+	r.Unreachable = current.Unreachable
+	return r
+}
+
+// findUnreachable stores the public notes which are unreachable from the root
+// files in the snapshot.
+func (s *Service) findUnreachable(snapshot *site.Snapshot) {
+	unreachable, err := s.UnreachablePublicNotes(snapshot.Links.Notes())
+	if err != nil {
+		s.Log.Warnf("Unable to search for unreachable notes: %s", err.Error())
+		return
+	}
+	snapshot.Unreachable = unreachable
+}
@@ -0,0 +1,60 @@
+package dropbox
+
+import (
+	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/sitemap"
+	"github.com/mlesniak/markdown/internal/utils"
+	"time"
+)
+
+// generateSitemap stores sitemap.xml and robots.txt in the cache. The last
+// modification of a page is taken from its front matter (lastmod) or, if not
+// available, from dropbox. Tag pages are as recent as their newest page. Notes
+// tagged with #noindex are omitted.
+func (s *Service) generateSitemap(snapshot *site.Snapshot, fileBuffers map[string][]byte, metadata map[string]Metadata, tagMap map[string][]string) {
+	lastMods := make(map[string]time.Time)
+	urls := []sitemap.URL{}
+	for filename, bs := range fileBuffers {
+		lastMod := lastModified(bs, metadata[filename])
+		lastMods[filename] = lastMod
+		if utils.HasTag(bs, utils.NoIndexTag) {
+			continue
+		}
+		urls = append(urls, sitemap.URL{
+			Path:    snapshot.Routes.Path(filename),
+			LastMod: lastMod,
+		})
+	}
+
+	for tag, filenames := range tagMap {
+		lastMod := time.Time{}
+		for _, filename := range filenames {
+			if lastMods[filename].After(lastMod) {
+				lastMod = lastMods[filename]
+			}
+		}
+		urls = append(urls, sitemap.URL{
+			Path:    utils.PagePath(utils.TagFilename(tag, ".md")),
+			LastMod: lastMod,
+		})
+	}
+
+	urls = append(urls, sitemap.URL{Path: "/tags"})
+
+	bs, err := sitemap.Generate(s.BaseURL, urls)
+	if err != nil {
+		s.Log.Warnf("Unable to generate sitemap: %s", err.Error())
+	} else {
+		s.Log.Infof("Adding sitemap to cache. urls=%d", len(urls))
+		cache.Get().AddEntry(cache.Entry{
+			Name: "sitemap.xml",
+			Data: bs,
+		})
+	}
+
+	cache.Get().AddEntry(cache.Entry{
+		Name: "robots.txt",
+		Data: sitemap.Robots(s.BaseURL, s.RobotsDisallow),
+	})
+}
@@ -0,0 +1,151 @@
+package dropbox
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshotVersion has to be increased whenever the persisted data changes in
+// an incompatible way. Snapshots of other versions are ignored.
+const snapshotVersion = 2
+
+const snapshotFilename = "snapshot.gob"
+
+// snapshotHeader starts every snapshot file, followed by the version, the
+// checksum of the data and the gob encoded persistedSnapshot.
+const snapshotHeader = "markdown-snapshot"
+
+// persistedSnapshot is everything needed to serve the site without crawling
+// dropbox first.
+type persistedSnapshot struct {
+	Created time.Time
+	Build   string
+	// Entries contains the rendered pages, feeds and cached media.
+	Entries          []cache.Entry
+	Site             *site.Snapshot
+	Revisions        map[string]Metadata
+	UnpublishedLinks map[string][]string
+}
+
+// SaveSnapshot writes the current site and cache to SnapshotDirectory, if set.
+// The file is replaced atomically, so a crash while saving keeps the previous
+// snapshot intact.
+func (s *Service) SaveSnapshot() error {
+	if s.SnapshotDirectory == "" {
+		return nil
+	}
+	start := time.Now()
+
+	var data bytes.Buffer
+	err := gob.NewEncoder(&data).Encode(persistedSnapshot{
+		Created:          time.Now(),
+		Build:            utils.BuildInformation(),
+		Entries:          cache.Get().Entries(),
+		Site:             site.Get(),
+		Revisions:        s.revisions,
+		UnpublishedLinks: s.unpublishedLinks,
+	})
+	if err != nil {
+		return err
+	}
+	checksum := sha256.Sum256(data.Bytes())
+
+	if err := os.MkdirAll(s.SnapshotDirectory, 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(s.SnapshotDirectory, snapshotFilename+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "%s %d\n%s\n", snapshotHeader, snapshotVersion, hex.EncodeToString(checksum[:]))
+	writer.Write(data.Bytes())
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), filepath.Join(s.SnapshotDirectory, snapshotFilename)); err != nil {
+		return err
+	}
+
+	s.Log.Infof("Saved snapshot. size=%d, duration=%dms", data.Len(), time.Since(start).Milliseconds())
+	return nil
+}
+
+// LoadSnapshot restores the site and cache from SnapshotDirectory. It returns
+// false if there is no usable snapshot, i.e. a full crawl is necessary.
+func (s *Service) LoadSnapshot() bool {
+	if s.SnapshotDirectory == "" {
+		return false
+	}
+
+	snapshot, err := readSnapshot(filepath.Join(s.SnapshotDirectory, snapshotFilename))
+	if os.IsNotExist(err) {
+		s.Log.Infof("No snapshot found. directory=%s", s.SnapshotDirectory)
+		return false
+	}
+	if err != nil {
+		s.Log.Warnf("Ignoring snapshot. error=%s", err.Error())
+		return false
+	}
+
+	cache.Get().Restore(snapshot.Entries)
+	site.Set(snapshot.Site)
+	s.revisions = snapshot.Revisions
+	s.unpublishedLinks = snapshot.UnpublishedLinks
+	s.Log.Infof("Loaded snapshot. created=%s, build=%s, entries=%d",
+		snapshot.Created.Format(time.RFC3339), snapshot.Build, len(snapshot.Entries))
+	return true
+}
+
+func readSnapshot(filename string) (persistedSnapshot, error) {
+	// Parts missing in the data keep their empty defaults.
+	snapshot := persistedSnapshot{Site: site.New()}
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// Header, checksum and data.
+	parts := bytes.SplitN(bs, []byte("\n"), 3)
+	if len(parts) != 3 {
+		return snapshot, errors.New("truncated snapshot")
+	}
+	header := strings.Fields(string(parts[0]))
+	if len(header) != 2 || header[0] != snapshotHeader {
+		return snapshot, errors.New("unknown snapshot format")
+	}
+	if header[1] != fmt.Sprint(snapshotVersion) {
+		return snapshot, fmt.Errorf("unsupported snapshot version %s", header[1])
+	}
+	checksum := sha256.Sum256(parts[2])
+	if string(parts[1]) != hex.EncodeToString(checksum[:]) {
+		return snapshot, errors.New("snapshot checksum mismatch")
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(parts[2])).Decode(&snapshot); err != nil {
+		return snapshot, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+	return snapshot, nil
+}
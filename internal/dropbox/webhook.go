@@ -15,6 +15,7 @@ type Updater func()
 type entry struct {
 	Tag  string `json:".tag"`
 	Name string `json:"name"`
+	Rev  string `json:"rev"`
 }
 
 // HandleChallenge returns the dropbox challenge which is used to check
@@ -0,0 +1,208 @@
+// Package feed generates Atom, RSS and JSON feeds for published notes.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/mlesniak/markdown/internal/utils"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Feed describes a single feed, e.g. for all notes or for all notes of a tag.
+type Feed struct {
+	Title string
+	// BaseURL is the absolute URL of the site without trailing slash.
+	BaseURL string
+	// Path of the feed itself, e.g. /feed.xml.
+	Path  string
+	Items []Item
+}
+
+// Item is a single note in a feed.
+type Item struct {
+	Title string
+	// Path is the absolute path of the note, e.g. /202009010520-index.md.
+	Path    string
+	Date    time.Time
+	Content string
+}
+
+// SortItems orders items by date, newest first.
+func (f *Feed) SortItems() {
+	sort.Slice(f.Items, func(i, j int) bool {
+		if f.Items[i].Date.Equal(f.Items[j].Date) {
+			return f.Items[i].Path < f.Items[j].Path
+		}
+		return f.Items[i].Date.After(f.Items[j].Date)
+	})
+}
+
+// updated returns the date of the newest item.
+func (f *Feed) updated() time.Time {
+	updated := time.Time{}
+	for _, item := range f.Items {
+		if item.Date.After(updated) {
+			updated = item.Date
+		}
+	}
+	return updated
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Atom renders the feed as Atom 1.0 document.
+func Atom(f Feed) ([]byte, error) {
+	af := atomFeed{
+		Title:   f.Title,
+		ID:      f.BaseURL + f.Path,
+		Updated: f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.BaseURL + "/"},
+			{Href: f.BaseURL + f.Path, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, item := range f.Items {
+		url := f.BaseURL + item.Path
+		af.Entries = append(af.Entries, atomEntry{
+			Title:   item.Title,
+			ID:      url,
+			Link:    atomLink{Href: url},
+			Updated: item.Date.Format(time.RFC3339),
+			Content: atomContent{Type: "html", Body: absoluteURLs(f.BaseURL, item.Content)},
+		})
+	}
+
+	return encodeXML(af)
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// RSS renders the feed as RSS 2.0 document.
+func RSS(f Feed) ([]byte, error) {
+	rf := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.BaseURL + "/",
+			Description:   f.Title,
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range f.Items {
+		url := f.BaseURL + item.Path
+		rf.Channel.Items = append(rf.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        url,
+			GUID:        url,
+			PubDate:     item.Date.Format(time.RFC1123Z),
+			Description: absoluteURLs(f.BaseURL, item.Content),
+		})
+	}
+
+	return encodeXML(rf)
+}
+
+type jsonItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	DatePublished string `json:"date_published"`
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Items       []jsonItem `json:"items"`
+}
+
+// JSON renders the feed as JSON Feed 1.1 document.
+func JSON(f Feed) ([]byte, error) {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.BaseURL + "/",
+		FeedURL:     f.BaseURL + f.Path,
+		Items:       []jsonItem{},
+	}
+	for _, item := range f.Items {
+		url := f.BaseURL + item.Path
+		jf.Items = append(jf.Items, jsonItem{
+			ID:            url,
+			URL:           url,
+			Title:         item.Title,
+			ContentHTML:   absoluteURLs(f.BaseURL, item.Content),
+			DatePublished: item.Date.Format(time.RFC3339),
+		})
+	}
+
+	return json.MarshalIndent(jf, "", "  ")
+}
+
+func encodeXML(v interface{}) ([]byte, error) {
+	bs, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bs...), nil
+}
+
+// absoluteURLs rewrites relative links and image sources in rendered html,
+// since feed readers display the content outside of our site.
+func absoluteURLs(baseURL string, html string) string {
+	rx := regexp.MustCompile(`(href|src)="([^"]*)"`)
+	return rx.ReplaceAllStringFunc(html, func(attribute string) string {
+		matches := rx.FindStringSubmatch(attribute)
+		return matches[1] + `="` + utils.AbsoluteURL(baseURL, matches[2]) + `"`
+	})
+}
@@ -0,0 +1,138 @@
+// Package graph describes the published notes, tags and the links between
+// them as a graph.
+package graph
+
+import "sort"
+
+// Kinds of nodes and edges.
+const (
+	NoteNode = "note"
+	TagNode  = "tag"
+
+	LinkEdge = "link"
+	TagEdge  = "tag"
+)
+
+// Node is either a note or a tag.
+type Node struct {
+	// ID is the filename of a note or the tag itself, e.g. #go.
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	// Path is the absolute path of the page of the node.
+	Path string `json:"path"`
+	// Date is the publication date of a note in RFC3339, if known.
+	Date     string `json:"date,omitempty"`
+	Incoming int    `json:"incoming"`
+	Outgoing int    `json:"outgoing"`
+}
+
+// Edge is either a link from a note to a note or the membership of a note in
+// a tag, pointing from the note to the tag.
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// Graph contains nodes and edges. Edges only reference existing nodes.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// New creates a graph. Edges referencing unknown nodes, e.g. links to
+// unpublished notes, are dropped, duplicate edges are merged and the
+// number of incoming and outgoing edges is computed for every node.
+func New(nodes []Node, edges []Edge) *Graph {
+	index := make(map[string]int)
+	for i, node := range nodes {
+		node.Incoming = 0
+		node.Outgoing = 0
+		nodes[i] = node
+		index[node.ID] = i
+	}
+
+	seen := make(map[Edge]struct{})
+	filtered := []Edge{}
+	for _, edge := range edges {
+		source, okSource := index[edge.Source]
+		target, okTarget := index[edge.Target]
+		if !okSource || !okTarget {
+			continue
+		}
+		if _, found := seen[edge]; found {
+			continue
+		}
+		seen[edge] = struct{}{}
+		filtered = append(filtered, edge)
+		nodes[source].Outgoing++
+		nodes[target].Incoming++
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].Source != filtered[j].Source {
+			return filtered[i].Source < filtered[j].Source
+		}
+		return filtered[i].Target < filtered[j].Target
+	})
+	return &Graph{Nodes: nodes, Edges: filtered}
+}
+
+// Neighborhood returns the subgraph of all nodes within depth hops of the
+// node, ignoring the direction of edges.
+func (g *Graph) Neighborhood(id string, depth int) (*Graph, bool) {
+	neighbors := make(map[string][]string)
+	for _, edge := range g.Edges {
+		neighbors[edge.Source] = append(neighbors[edge.Source], edge.Target)
+		neighbors[edge.Target] = append(neighbors[edge.Target], edge.Source)
+	}
+
+	found := false
+	for _, node := range g.Nodes {
+		if node.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Breadth-first search up to the given depth.
+	visited := map[string]struct{}{id: {}}
+	frontier := []string{id}
+	for hop := 0; hop < depth; hop++ {
+		next := []string{}
+		for _, current := range frontier {
+			for _, neighbor := range neighbors[current] {
+				if _, ok := visited[neighbor]; ok {
+					continue
+				}
+				visited[neighbor] = struct{}{}
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+
+	nodes := []Node{}
+	for _, node := range g.Nodes {
+		if _, ok := visited[node.ID]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+	edges := []Edge{}
+	for _, edge := range g.Edges {
+		_, okSource := visited[edge.Source]
+		_, okTarget := visited[edge.Target]
+		if okSource && okTarget {
+			edges = append(edges, edge)
+		}
+	}
+	// Counts describe the whole graph, hence we do not use New.
+	return &Graph{Nodes: nodes, Edges: edges}, true
+}
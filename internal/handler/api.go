@@ -0,0 +1,248 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/dropbox"
+	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Default and maximum number of notes per page of the API.
+	apiPageSize    = 20
+	maxAPIPageSize = 100
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// apiNote is the summary of a note in API responses.
+type apiNote struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Title       string     `json:"title"`
+	Path        string     `json:"path"`
+	Date        *time.Time `json:"date,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags"`
+}
+
+// apiBacklink is a note linking to another one with the context of each link.
+type apiBacklink struct {
+	apiNote
+	Contexts []string `json:"contexts"`
+}
+
+// notesPage is a page of notes.
+type notesPage struct {
+	Page  int       `json:"page"`
+	Pages int       `json:"pages"`
+	Total int       `json:"total"`
+	Notes []apiNote `json:"notes"`
+}
+
+// NotesHandler lists all notes, newest first. The parameters tag, since and
+// until filter the notes, page and per_page select the page.
+func NotesHandler(c echo.Context) error {
+	snapshot := site.Get()
+
+	var tagged map[string]struct{}
+	if tag := c.QueryParam("tag"); tag != "" {
+		tag = utils.CanonicalTag("#" + strings.TrimPrefix(tag, "#"))
+		tagged = make(map[string]struct{})
+		for _, filename := range snapshot.Tags.Notes(tag) {
+			tagged[filename] = struct{}{}
+		}
+	}
+	since, hasSince := utils.ParseDate(c.QueryParam("since"))
+	until, hasUntil := utils.ParseDate(c.QueryParam("until"))
+
+	notes := []apiNote{}
+	for _, note := range allNotes(snapshot) {
+		if _, found := tagged[note.Name]; tagged != nil && !found {
+			continue
+		}
+		if hasSince && (note.Date == nil || note.Date.Before(since)) {
+			continue
+		}
+		if hasUntil && (note.Date == nil || note.Date.After(until)) {
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	perPage, err := strconv.Atoi(c.QueryParam("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = apiPageSize
+	}
+	if perPage > maxAPIPageSize {
+		perPage = maxAPIPageSize
+	}
+	pages := (len(notes) + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+	page := pageParam(c)
+	if page < 1 || page > pages {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Page not found"})
+	}
+	end := page * perPage
+	if end > len(notes) {
+		end = len(notes)
+	}
+
+	return jsonWithETag(c, notesPage{
+		Page:  page,
+		Pages: pages,
+		Total: len(notes),
+		Notes: notes[(page-1)*perPage : end],
+	})
+}
+
+// RecentHandler lists the newest notes with a publication date. The
+// parameter limit defaults to 10.
+func RecentHandler(c echo.Context) error {
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > maxAPIPageSize {
+		limit = maxAPIPageSize
+	}
+
+	notes := []apiNote{}
+	for _, note := range allNotes(site.Get()) {
+		// Notes without date are sorted last.
+		if note.Date == nil || len(notes) == limit {
+			break
+		}
+		notes = append(notes, note)
+	}
+	return jsonWithETag(c, notes)
+}
+
+// NoteHandler returns a single note, see markdown.Document. The parameter id
+// is the Zettelkasten ID or any other path of the note.
+func NoteHandler(c echo.Context) error {
+	filename, ok := resolveID(c)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Note not found"})
+	}
+	entry, inCache := useCache(c.Logger(), cache.VariantName(filename, cache.JSON))
+	if !inCache {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Note not found"})
+	}
+	return serveEntry(c, entry, echo.MIMEApplicationJSONCharsetUTF8, cacheControl.HTML)
+}
+
+// BacklinksHandler lists the notes linking to a note with the markdown
+// context of each link. Links to unpublished notes in the context are
+// replaced like in the markdown of notes.
+func BacklinksHandler(dropbox *dropbox.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		filename, ok := resolveID(c)
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Note not found"})
+		}
+
+		snapshot := site.Get()
+		renderer := markdown.Renderer{
+			Published:           make(map[string]struct{}),
+			UnpublishedLinkText: dropbox.UnpublishedLinkText,
+		}
+		for note := range snapshot.Notes {
+			renderer.Published[note] = struct{}{}
+		}
+		contexts := make(map[string][]string)
+		for _, occurrence := range snapshot.Links.Incoming(filename) {
+			context := string(renderer.PublicMarkdown([]byte(occurrence.Context)))
+			contexts[occurrence.Source] = append(contexts[occurrence.Source], context)
+		}
+		backlinks := []apiBacklink{}
+		for _, source := range snapshot.Links.Parents(filename) {
+			backlinks = append(backlinks, apiBacklink{
+				apiNote:  noteSummary(snapshot, source),
+				Contexts: contexts[source],
+			})
+		}
+		return jsonWithETag(c, backlinks)
+	}
+}
+
+// TagsAPIHandler lists all tags with their number of notes.
+func TagsAPIHandler(c echo.Context) error {
+	return jsonWithETag(c, site.Get().Tags.Links(c.QueryParam("sort") == "count"))
+}
+
+// OpenAPIHandler returns the OpenAPI document describing the API.
+func OpenAPIHandler(c echo.Context) error {
+	return blobWithETag(c, echo.MIMEApplicationJSONCharsetUTF8, openAPI)
+}
+
+// allNotes returns the summaries of all notes, newest first.
+func allNotes(snapshot *site.Snapshot) []apiNote {
+	notes := []apiNote{}
+	for filename := range snapshot.Notes {
+		notes = append(notes, noteSummary(snapshot, filename))
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if (a.Date == nil) != (b.Date == nil) {
+			return a.Date != nil
+		}
+		if a.Date != nil && !a.Date.Equal(*b.Date) {
+			return a.Date.After(*b.Date)
+		}
+		return a.Name < b.Name
+	})
+	return notes
+}
+
+func noteSummary(snapshot *site.Snapshot, filename string) apiNote {
+	summary, ok := snapshot.Notes[filename]
+	if !ok {
+		summary.Title = markdown.VisibleLink(filename)
+	}
+	note := apiNote{
+		ID:          snapshot.Routes.ID(filename),
+		Name:        filename,
+		Title:       summary.Title,
+		Path:        snapshot.Routes.Path(filename),
+		Description: summary.Description,
+		Tags:        snapshot.Tags.NoteTags(filename),
+	}
+	if !summary.Date.IsZero() {
+		date := summary.Date
+		note.Date = &date
+	}
+	return note
+}
+
+// resolveID returns the published note of the parameter id.
+func resolveID(c echo.Context) (string, bool) {
+	snapshot := site.Get()
+	filename, _, ok := snapshot.Routes.Resolve("/" + c.Param("id"))
+	if !ok {
+		return "", false
+	}
+	_, published := snapshot.Notes[filename]
+	return filename, published
+}
+
+// jsonWithETag returns the value as JSON, see blobWithETag.
+func jsonWithETag(c echo.Context, value interface{}) error {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return blobWithETag(c, echo.MIMEApplicationJSONCharsetUTF8, bs)
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/cache"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CacheControl contains the Cache-Control headers per kind of resource.
+type CacheControl struct {
+	// HTML is used for pages, feeds and API responses.
+	HTML string
+	// Media is used for images.
+	Media string
+	// Static is used for the static files of the theme.
+	Static string
+}
+
+var cacheControl = CacheControl{
+	HTML:   "public, max-age=0, must-revalidate",
+	Media:  "public, max-age=86400",
+	Static: "public, max-age=3600",
+}
+
+// SetCacheControl configures the Cache-Control headers. Empty values keep the
+// defaults.
+func SetCacheControl(cc CacheControl) {
+	if cc.HTML != "" {
+		cacheControl.HTML = cc.HTML
+	}
+	if cc.Media != "" {
+		cacheControl.Media = cc.Media
+	}
+	if cc.Static != "" {
+		cacheControl.Static = cc.Static
+	}
+}
+
+// serveEntry serves a cache entry with its ETag and modification time, which
+// answers conditional requests (If-None-Match, If-Modified-Since) with 304 Not
+// Modified. Compressed data is served if accepted by the client.
+func serveEntry(c echo.Context, entry cache.Entry, contentType string, control string) error {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Cache-Control", control)
+
+	data, etag := entry.Data, entry.ETag
+	if entry.Gzip != nil || entry.Brotli != nil {
+		header.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		encoding := acceptedEncoding(c.Request(), entry)
+		switch encoding {
+		case "br":
+			data = entry.Brotli
+		case "gzip":
+			data = entry.Gzip
+		}
+		if encoding != "" {
+			// Every representation needs its own entity tag.
+			header.Set(echo.HeaderContentEncoding, encoding)
+			etag = strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+		}
+	}
+	header.Set("ETag", etag)
+
+	http.ServeContent(c.Response(), c.Request(), entry.Name, entry.ModTime, bytes.NewReader(data))
+	return nil
+}
+
+// acceptedEncoding returns the preferred encoding of the entry accepted by the
+// request, i.e. br or gzip, or an empty string for the uncompressed data.
+func acceptedEncoding(request *http.Request, entry cache.Entry) string {
+	accepted := make(map[string]bool)
+	for _, encoding := range strings.Split(request.Header.Get(echo.HeaderAcceptEncoding), ",") {
+		parts := strings.Split(encoding, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		accepted[name] = true
+		for _, parameter := range parts[1:] {
+			parameter = strings.ReplaceAll(parameter, " ", "")
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(parameter, "q="), 64); err == nil && q == 0 {
+				accepted[name] = false
+			}
+		}
+	}
+
+	switch {
+	case entry.Brotli != nil && accepted["br"]:
+		return "br"
+	case entry.Gzip != nil && accepted["gzip"]:
+		return "gzip"
+	default:
+		return ""
+	}
+}
+
+// blobWithETag serves data computed on request like a cache entry, see
+// serveEntry.
+func blobWithETag(c echo.Context, contentType string, bs []byte) error {
+	return serveEntry(c, cache.Entry{Data: bs, ETag: cache.ETag(bs)}, contentType, cacheControl.HTML)
+}
+
+// Static files by name, including their ETag and compressed data. Static files
+// do not change while running, hence they are read and compressed only once.
+var staticFiles sync.Map
+
+// serveStatic serves a file of the static directory of the theme like a
+// cache entry. Embedded files have no modification time, hence their ETag is
+// necessary for conditional requests.
+func serveStatic(c echo.Context, static fs.FS, name string) bool {
+	if name == "" || !fs.ValidPath(name) {
+		return false
+	}
+
+	entry, ok := staticFiles.Load(name)
+	if !ok {
+		info, err := fs.Stat(static, name)
+		if err != nil || info.IsDir() {
+			return false
+		}
+		bs, err := fs.ReadFile(static, name)
+		if err != nil {
+			return false
+		}
+		file := cache.Entry{Name: name, Data: bs, ETag: cache.ETag(bs), ModTime: info.ModTime()}
+		cache.Compress(&file)
+		entry, _ = staticFiles.LoadOrStore(name, file)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(entry.(cache.Entry).Data)
+	}
+	serveEntry(c, entry.(cache.Entry), contentType, cacheControl.Static)
+	return true
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// Maximum number of hops for local graphs.
+	maxGraphDepth = 3
+)
+
+// GraphHandler returns the graph of all published notes and tags as JSON. If
+// the parameter note is set, only its neighbors within depth hops are returned.
+func GraphHandler(c echo.Context) error {
+	g := site.Get().Graph
+
+	note := c.QueryParam("note")
+	if note == "" {
+		return c.JSON(http.StatusOK, g)
+	}
+
+	depth, err := strconv.Atoi(c.QueryParam("depth"))
+	if err != nil || depth < 1 {
+		depth = 1
+	}
+	if depth > maxGraphDepth {
+		depth = maxGraphDepth
+	}
+	local, ok := g.Neighborhood(note, depth)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Note not found"})
+	}
+	return c.JSON(http.StatusOK, local)
+}
+
+// GraphPageHandler renders the page displaying the whole graph.
+func GraphPageHandler(c echo.Context) error {
+	bs, err := templates.Get().Render(templates.Graph, templates.Page{
+		Title:       "Graph",
+		Description: "All notes and tags and how they are linked",
+		Build:       utils.BuildInformation(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.HTMLBlob(http.StatusOK, bs)
+}
@@ -4,104 +4,283 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/mlesniak/markdown/internal/cache"
 	"github.com/mlesniak/markdown/internal/dropbox"
+	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
-	"os"
+	"path"
 	"strings"
+	"unicode"
 )
 
 const (
-	// Directory containing static files for website.
-	staticRoot = "static/"
+	// Maximum number of notes suggested on the 404 page.
+	maxSuggestions = 5
 )
 
+// generatedFiles are created while updating the cache and take precedence over
+// static files with the same name.
+var generatedFiles = map[string]string{
+	"robots.txt":  "text/plain; charset=UTF-8",
+	"sitemap.xml": "application/xml; charset=UTF-8",
+}
+
+// mediaTypes are the content types of the files served from the media
+// directory. Other paths are not looked up in dropbox.
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".pdf":  "application/pdf",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+}
+
 // ContentHandler is the default handler for all non-static content. It uses the parameter name
 // to download the correct markdown file from dropbox, perform various transformations
 // and convert it to html.
-func ContentHandler(dropbox *dropbox.Service) echo.HandlerFunc {
+//
+// Notes are served under their canonical path, see routes.Table. Other forms, e.g. the
+// filename, are redirected permanently.
+//
+// Files in the static directory of the theme are served in the root directory as well.
+func ContentHandler(dropbox *dropbox.Service, static fs.FS) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		log := c.Logger()
 		filename := c.Param("name")
 
-		// Check if filename exists in static root directory. This is secure without checking
-		// for parent paths (..) etc since we run in a docker container.
-		ok := serveStaticFile(c, filename)
+		if contentType, ok := generatedFiles[filename]; ok {
+			entry, inCache := useCache(log, filename)
+			if inCache {
+				return serveEntry(c, entry, contentType, cacheControl.HTML)
+			}
+		}
+
+		// Check if filename exists in the static directory of the theme.
+		ok := serveStaticFile(c, static, filename)
 		if ok {
 			return nil
 		}
 
+		if note, canonical, ok := site.Get().Routes.Resolve("/" + filename); ok {
+			if !canonical {
+				return redirect(c, site.Get().Routes.Path(note))
+			}
+			return serveNote(c, note)
+		}
+
 		// Compute suffix.
-		parts := strings.Split(filename, ".")
-		if len(parts) < 1 {
-			log.Warnf("Filename without suffix. filename=%s", filename)
-			return c.String(http.StatusNotFound, "File not found:"+filename)
+		suffix := ""
+		if parts := strings.Split(filename, "."); len(parts) > 1 {
+			suffix = parts[len(parts)-1]
 		}
-		suffix := parts[1]
 
 		// Load data based on suffix.
 		switch suffix {
-		case "png":
-			bs, inCache := useCache(log, filename)
-			if !inCache {
-				// Load file once into cache.
-				tmp, err := dropbox.Read("media/" + filename)
-				if err != nil {
-					return c.String(http.StatusNotFound, "File not found:"+filename)
-				}
-				bs = tmp
-				inCache = true
-				cache.Get().AddEntry(cache.Entry{
-					Name: filename,
-					Data: bs,
-				})
-			}
-			return c.Blob(http.StatusOK, "image/png", bs)
 		case "md", "":
-			// Markdown files are initially cached.
-			bs, inCache := useCache(log, filename)
+			// Tag pages are rendered on request, since they can be sorted and paginated.
+			if tag, ok := site.Get().Tags.Find(filename); ok {
+				return tagPage(c, dropbox.BaseURL, tag)
+			}
+			if tag, ok := site.Get().Tags.FindAlternative(filename); ok {
+				return redirect(c, utils.PagePath(utils.TagFilename(tag, ".md")))
+			}
+
+			return notFound(c)
+		case "xml", "json":
+			// Feeds are generated while updating the cache.
+			entry, inCache := useCache(log, filename)
 			if !inCache {
-				return c.String(http.StatusNotFound, "File not found:"+filename)
+				if tag, ok := site.Get().Tags.FindAlternative(filename); ok && suffix == "xml" && utils.TagFilename(tag, ".xml") != filename {
+					return redirect(c, utils.PagePath(utils.TagFilename(tag, ".xml")))
+				}
+				return notFound(c)
 			}
-			return c.Blob(http.StatusOK, "text/html; charset=UTF-8", bs)
+			return serveEntry(c, entry, feedContentType(filename), cacheControl.HTML)
 		default:
-			return c.String(http.StatusNotFound, "File not found:"+filename)
+			return serveMedia(c, dropbox, filename)
 		}
+	}
+}
 
-		return nil
+// serveMedia serves a file of the media directory. Small files are loaded
+// once into the cache, larger ones are streamed from dropbox on every request.
+// Both support range requests.
+func serveMedia(c echo.Context, dropbox *dropbox.Service, filename string) error {
+	contentType, ok := mediaTypes[strings.ToLower(path.Ext(filename))]
+	if !ok {
+		return notFound(c)
+	}
+
+	entry, inCache := useCache(c.Logger(), filename)
+	if inCache {
+		return serveEntry(c, entry, contentType, cacheControl.Media)
+	}
+
+	media, err := dropbox.OpenMedia(c.Request().Context(), "media/"+filename)
+	if err != nil {
+		return notFound(c)
+	}
+	defer media.Close()
+
+	if media.Size <= dropbox.MediaCacheLimit {
+		bs, err := ioutil.ReadAll(media)
+		if err != nil {
+			return err
+		}
+		entry = cache.Get().AddEntry(cache.Entry{
+			Name:      filename,
+			Data:      bs,
+			ETag:      media.ETag(),
+			ModTime:   media.ServerModified,
+			Evictable: true,
+		})
+		return serveEntry(c, entry, contentType, cacheControl.Media)
+	}
+
+	c.Logger().Infof("Streaming large media file. filename=%s, size=%d", filename, media.Size)
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("ETag", media.ETag())
+	header.Set("Cache-Control", cacheControl.Media)
+	http.ServeContent(c.Response(), c.Request(), filename, media.ServerModified, media)
+	return nil
+}
+
+// IndexHandler serves the index note under the root path.
+func IndexHandler(filename string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return serveNote(c, filename)
+	}
+}
+
+// serveNote serves a note, which is rendered while updating the cache, as html
+// or, see negotiate, as markdown or JSON.
+func serveNote(c echo.Context, filename string) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	name, contentType := filename, "text/html; charset=UTF-8"
+	switch negotiate(c) {
+	case cache.Markdown:
+		name, contentType = cache.VariantName(filename, cache.Markdown), "text/markdown; charset=UTF-8"
+	case cache.JSON:
+		name, contentType = cache.VariantName(filename, cache.JSON), echo.MIMEApplicationJSONCharsetUTF8
+	}
+
+	entry, inCache := useCache(c.Logger(), name)
+	if !inCache {
+		return notFound(c)
+	}
+	return serveEntry(c, entry, contentType, cacheControl.HTML)
+}
+
+// negotiate returns the requested format of a note: html, markdown or json.
+// The parameter format takes precedence over the Accept header, in which the
+// first supported media type wins.
+func negotiate(c echo.Context) string {
+	switch format := c.QueryParam("format"); format {
+	case "html", cache.Markdown, cache.JSON:
+		return format
+	}
+
+	for _, mediaType := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType = strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0])
+		switch mediaType {
+		case "text/html", "application/xhtml+xml", "*/*":
+			return "html"
+		case "text/markdown", "text/x-markdown":
+			return cache.Markdown
+		case echo.MIMEApplicationJSON:
+			return cache.JSON
+		}
+	}
+	return "html"
+}
+
+// redirect redirects permanently to the path, keeping the query.
+func redirect(c echo.Context, path string) error {
+	if query := c.Request().URL.RawQuery; query != "" {
+		path += "?" + query
+	}
+	return c.Redirect(http.StatusMovedPermanently, path)
+}
+
+// notFound renders the 404 page, suggesting notes similar to the requested
+// path. The search form is prefilled with the words of the path.
+func notFound(c echo.Context) error {
+	path := c.Request().URL.Path
+	suggestions := []templates.Link{}
+	for _, note := range site.Get().Routes.Suggest(path, maxSuggestions) {
+		suggestions = append(suggestions, templates.Link{
+			Title: markdown.VisibleLink(note),
+			Path:  site.Get().Routes.Path(note),
+		})
+	}
+	query := strings.Join(strings.FieldsFunc(strings.TrimSuffix(path, ".md"), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+
+	bs, err := templates.Get().Render(templates.NotFound, templates.Page{
+		Title: "Page not found",
+		Notes: suggestions,
+		Query: query,
+		Build: utils.BuildInformation(),
+	})
+	if err != nil {
+		c.Logger().Warnf("Unable to render 404 page: %s", err.Error())
+		return c.String(http.StatusNotFound, "Page not found")
+	}
+	return c.HTMLBlob(http.StatusNotFound, bs)
+}
+
+// feedContentType returns the content type of a generated feed.
+func feedContentType(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, ".json"):
+		return "application/feed+json; charset=UTF-8"
+	case filename == "rss.xml":
+		return "application/rss+xml; charset=UTF-8"
+	default:
+		return "application/atom+xml; charset=UTF-8"
 	}
 }
 
 // serveStaticFile is a special handler to service static files in the root directory
 // which are actually stored in the static folder.
-func serveStaticFile(c echo.Context, filename string) bool {
-	log := c.Logger()
-
-	if filename != "" {
-		virtualPath := staticRoot + filename
-		_, err := os.Stat(virtualPath)
-		if err == nil {
-			log.Infof("Serving static virtual file. filename=%s", filename)
-			c.File(virtualPath)
-			return true
+func serveStaticFile(c echo.Context, static fs.FS, filename string) bool {
+	ok := serveStatic(c, static, filename)
+	if ok {
+		c.Logger().Infof("Serving static virtual file. filename=%s", filename)
+	}
+	return ok
+}
+
+// StaticHandler serves the static directory of the theme.
+func StaticHandler(static fs.FS) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !serveStatic(c, static, c.Param("*")) {
+			return notFound(c)
 		}
+		return nil
 	}
-	return false
 }
 
 // useCache tries to use the cache entry to serve a precomputed and stored file.
-func useCache(log echo.Logger, filename string) ([]byte, bool) {
-	entry, ok := cache.Get().GetEntry(filename)
+func useCache(log echo.Logger, filename string) (cache.Entry, bool) {
+	entry, ok := cache.Get().Get(filename)
 	if ok {
 		log.Infof("Using cache. filename=%s", filename)
-		return entry, true
 	}
-
-	// Try to replace all - with spaces.
-	spaceFilename := strings.ReplaceAll(filename, "-", " ")
-	entry, ok = cache.Get().GetEntry(spaceFilename)
-	if ok {
-		log.Infof("Using cache (after replacing -s). filename=%s, retrievedFilename=%s", filename, spaceFilename)
-		return entry, true
-	}
-
-	return []byte{}, false
+	return entry, ok
 }
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/site"
+	"net/http"
+)
+
+// Redirects redirects old paths, configured in the front matter of notes or
+// the redirect file, permanently to their new path.
+func Redirects() func(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+			if method != http.MethodGet && method != http.MethodHead {
+				return next(c)
+			}
+			if to, ok := site.Get().Routes.Redirect(c.Request().URL.Path); ok {
+				return redirect(c, to)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/dropbox"
+	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"net/http"
+)
+
+// ReportHandler renders the report of orphans, dead ends, hubs and unreachable
+// notes. It must only be available to administrators.
+func ReportHandler(dropbox *dropbox.Service, rootFiles []string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := dropbox.Report(rootFiles)
+
+		hubs := []templates.Link{}
+		for _, hub := range r.Hubs {
+			link := noteLink(hub.Note)
+			link.Count = hub.Incoming
+			hubs = append(hubs, link)
+		}
+		unreachable := []templates.Link{}
+		for _, note := range r.Unreachable {
+			// Unreachable notes are not published, hence we can not link to them.
+			unreachable = append(unreachable, templates.Link{Title: note})
+		}
+
+		bs, err := templates.Get().Render(templates.Report, templates.Page{
+			Title: "Report",
+			Sections: []templates.Section{
+				{Title: "Orphans", Description: "Notes without incoming links besides the one from the index.", Notes: noteLinks(r.Orphans)},
+				{Title: "Dead ends", Description: "Notes without outgoing links.", Notes: noteLinks(r.DeadEnds)},
+				{Title: "Hubs", Description: "The most linked notes and the number of notes linking to them.", Notes: hubs},
+				{Title: "Unreachable", Description: "Public notes which can not be reached from the index.", Notes: unreachable},
+			},
+			Build: utils.BuildInformation(),
+		})
+		if err != nil {
+			return err
+		}
+		return c.HTMLBlob(http.StatusOK, bs)
+	}
+}
+
+func noteLinks(notes []string) []templates.Link {
+	links := []templates.Link{}
+	for _, note := range notes {
+		links = append(links, noteLink(note))
+	}
+	return links
+}
+
+func noteLink(note string) templates.Link {
+	return templates.Link{Title: markdown.VisibleLink(note), Path: site.Get().Routes.Path(note)}
+}
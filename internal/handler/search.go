@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/cache"
+	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SearchHandler lists all cached notes whose title contains every word of the
+// query parameter q.
+func SearchHandler(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+	words := strings.Fields(strings.ToLower(query))
+
+	notes := []templates.Link{}
+	if len(words) > 0 {
+		paths := site.Get().Routes
+		names := cache.Get().List()
+		sort.Strings(names)
+		for _, name := range names {
+			if !strings.HasSuffix(name, ".md") || strings.HasPrefix(name, "tag-") {
+				continue
+			}
+			title := markdown.VisibleLink(name)
+			if containsAll(strings.ToLower(title), words) {
+				notes = append(notes, templates.Link{Title: title, Path: paths.Path(name)})
+			}
+		}
+	}
+
+	bs, err := templates.Get().Render(templates.Search, templates.Page{
+		Title: "Search",
+		Query: query,
+		Notes: notes,
+		Build: utils.BuildInformation(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.HTMLBlob(http.StatusOK, bs)
+}
+
+func containsAll(s string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(s, word) {
+			return false
+		}
+	}
+	return true
+}
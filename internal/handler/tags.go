@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/site"
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// TagsHandler renders the index of all tags, sorted by name or, with the
+// parameter sort=count, by their number of notes.
+func TagsHandler(c echo.Context) error {
+	index := site.Get().Tags
+	byCount := c.QueryParam("sort") == "count"
+
+	bs, err := templates.Get().Render(templates.Tags, templates.Page{
+		Title:       "Tags",
+		Description: "All tags and their number of articles",
+		Tags:        index.Links(byCount),
+		TagCloud:    index.Links(false),
+		Query:       c.QueryParam("sort"),
+		Build:       utils.BuildInformation(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.HTMLBlob(http.StatusOK, bs)
+}
+
+// TagHandler returns a page of the notes of a tag as JSON. The parameter name
+// is the name of the tag page, e.g. lang--go for #lang/go. Parameters are the
+// same as for tag pages.
+func TagHandler(c echo.Context) error {
+	snapshot := site.Get()
+	tag, ok := snapshot.Tags.Find(utils.TagFilename(c.Param("name"), ""))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Tag not found"})
+	}
+	listing, ok := snapshot.Tags.List(tag, snapshot.Notes, c.QueryParam("sort"), pageParam(c))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Page not found"})
+	}
+	return jsonWithETag(c, listing)
+}
+
+// tagPage renders a page of the notes of a tag, sorted by title or, with
+// sort=date, newest first. The parameter page selects the page.
+func tagPage(c echo.Context, baseURL string, tag string) error {
+	snapshot := site.Get()
+	listing, ok := snapshot.Tags.List(tag, snapshot.Notes, c.QueryParam("sort"), pageParam(c))
+	if !ok {
+		return notFound(c)
+	}
+	bs, err := listing.Render(baseURL)
+	if err != nil {
+		return err
+	}
+	return blobWithETag(c, echo.MIMETextHTMLCharsetUTF8, bs)
+}
+
+// pageParam returns the requested page, which defaults to the first one.
+func pageParam(c echo.Context) int {
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil {
+		return 1
+	}
+	return page
+}
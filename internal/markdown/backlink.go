@@ -1,43 +1,72 @@
 package markdown
 
 import (
-	"fmt"
-	"github.com/mlesniak/markdown/internal/backlinks"
+	"github.com/mlesniak/markdown/internal/mentions"
+	"github.com/mlesniak/markdown/internal/templates"
 	"github.com/mlesniak/markdown/internal/utils"
-	"regexp"
+	"github.com/russross/blackfriday/v2"
+	"html/template"
 	"sort"
 	"strings"
 )
 
-func generateBacklinkHTML(filename string) string {
-	buf := strings.Builder{}
-	links := backlinks.Get().GetParents(filename)
-	if len(links) > 0 {
-		// Sort links by timestamp (for now).
-		sort.Strings(links)
-
-		// Generate HTML.
-		buf.WriteString(`<hr/>This page is referenced by<ul>`)
-		for _, name := range links {
-			displayName := visibleLink(name)
-			link := fmt.Sprintf(`<li><a href="/%s">%s</a></li>`, name, displayName)
-			buf.WriteString("\n")
-			buf.WriteString(link)
-		}
-		buf.WriteString(`</ul>`)
+// backlinkList returns links to all pages referencing the file together with
+// an excerpt around each reference.
+func (r *Renderer) backlinkList(filename string) []templates.Backlink {
+	excerpts := make(map[string][]template.HTML)
+	for _, occurrence := range r.Links.Incoming(filename) {
+		excerpts[occurrence.Source] = append(excerpts[occurrence.Source], r.renderExcerpt(occurrence.Context))
 	}
-	backLinkHTML := buf.String()
-	return backLinkHTML
+	return r.excerptList(excerpts)
 }
 
-// visibleLink converts a filename to a displayable variant, i.e. for
-// the name 202009010520 Index foo bar.md it returns `Index Foo Bar`.
-func visibleLink(filename string) string {
-	rx := regexp.MustCompile(`\d* ?(.*?)\.md`)
-	matches := rx.FindStringSubmatch(filename)
-	if len(matches) < 1 {
-		return utils.AutoCaptialize(filename)
+// mentionList returns links to all pages mentioning the title of the file
+// without linking to it, together with an excerpt around each mention.
+func (r *Renderer) mentionList(filename string) []templates.Backlink {
+	excerpts := make(map[string][]template.HTML)
+	for _, mention := range mentions.Get().GetMentions(filename) {
+		excerpts[mention.Source] = append(excerpts[mention.Source], r.renderExcerpt(mention.Context))
+	}
+	return r.excerptList(excerpts)
+}
+
+// excerptList converts excerpts by referencing file to a sorted list of links.
+func (r *Renderer) excerptList(excerpts map[string][]template.HTML) []templates.Backlink {
+	// Sort links by timestamp (for now).
+	links := []string{}
+	for name := range excerpts {
+		links = append(links, name)
 	}
+	sort.Strings(links)
 
-	return utils.AutoCaptialize(matches[1])
+	list := []templates.Backlink{}
+	for _, name := range links {
+		list = append(list, templates.Backlink{
+			Link: templates.Link{
+				Title: VisibleLink(name),
+				Path:  r.Routes.Path(name),
+			},
+			Excerpts: excerpts[name],
+		})
+	}
+	return list
+}
+
+// renderExcerpt renders the context of a link as inline html.
+func (r *Renderer) renderExcerpt(context string) template.HTML {
+	markdown := r.processRawMarkdown([]byte(context))
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{})
+	html := string(blackfriday.Run([]byte(markdown), blackfriday.WithRenderer(renderer)))
+
+	// Remove the surrounding paragraph, since the excerpt is embedded in a list.
+	html = strings.TrimSpace(html)
+	html = strings.TrimPrefix(html, "<p>")
+	html = strings.TrimSuffix(html, "</p>")
+	return template.HTML(html)
+}
+
+// VisibleLink converts a filename to a displayable variant, i.e. for
+// the name 202009010520 Index foo bar.md it returns `Index Foo Bar`.
+func VisibleLink(filename string) string {
+	return utils.AutoCaptialize(utils.FilenameTitle(filename))
 }
@@ -0,0 +1,67 @@
+package markdown
+
+import (
+	"github.com/mlesniak/markdown/internal/templates"
+	"strings"
+	"time"
+)
+
+// Document is the JSON representation of a note.
+type Document struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	// Date is the publication date, if known.
+	Date *time.Time `json:"date,omitempty"`
+	// Modified is the date of the last modification, if known.
+	Modified  *time.Time       `json:"modified,omitempty"`
+	Tags      []string         `json:"tags"`
+	Links     []templates.Link `json:"links"`
+	Backlinks []templates.Link `json:"backlinks"`
+	HTML      string           `json:"html"`
+}
+
+// ToDocument converts a rendered page of a note to its JSON representation.
+func (r *Renderer) ToDocument(filename string, page templates.Page, modified time.Time) Document {
+	document := Document{
+		Name:        filename,
+		Title:       page.Title,
+		Path:        r.Routes.Path(filename),
+		URL:         page.Canonical,
+		Description: page.Description,
+		Tags:        []string{},
+		Links:       []templates.Link{},
+		Backlinks:   []templates.Link{},
+		HTML:        string(page.Content),
+	}
+	if !page.Date.IsZero() {
+		document.Date = &page.Date
+	}
+	if !modified.IsZero() {
+		document.Modified = &modified
+	}
+	for _, tag := range page.Tags {
+		document.Tags = append(document.Tags, tag.Title)
+	}
+	for _, child := range r.Links.Children(filename) {
+		document.Links = append(document.Links, templates.Link{Title: VisibleLink(child), Path: r.Routes.Path(child)})
+	}
+	for _, backlink := range page.Backlinks {
+		document.Backlinks = append(document.Backlinks, backlink.Link)
+	}
+	return document
+}
+
+// PublicMarkdown returns the original markdown of a note, including its front
+// matter, with links to unpublished notes replaced by UnpublishedLinkText.
+func (r *Renderer) PublicMarkdown(data []byte) []byte {
+	return wikiLinkRegex.ReplaceAllFunc(data, func(link []byte) []byte {
+		name := strings.TrimSuffix(strings.TrimPrefix(string(link), "[["), "]]")
+		if r.isPublished(name) {
+			return link
+		}
+		return []byte(r.UnpublishedLinkText)
+	})
+}
@@ -1,37 +1,101 @@
 package markdown
 
 import (
-	"errors"
-	"github.com/labstack/echo/v4"
+	"github.com/mlesniak/markdown/internal/backlinks"
+	"github.com/mlesniak/markdown/internal/routes"
+	"github.com/mlesniak/markdown/internal/templates"
 	"github.com/mlesniak/markdown/internal/utils"
 	"github.com/russross/blackfriday/v2"
-	"io/ioutil"
+	"html"
+	"html/template"
+	"mime"
+	"path"
 	"strings"
 )
 
-func ToHTML(log echo.Logger, filename string, data []byte) (string, error) {
+// Renderer converts the markdown files of a cache update.
+type Renderer struct {
+	// BaseURL is necessary to compute absolute URLs for metadata, e.g. the
+	// canonical URL of the page.
+	BaseURL string
+	// Published contains the filenames of all published notes. Links to other
+	// notes are not rendered.
+	Published map[string]struct{}
+	// Links between all published notes, used for backlinks.
+	Links *backlinks.Graph
+	// Routes contains the paths of all published notes.
+	Routes *routes.Table
+	// UnpublishedLinkText replaces links to unpublished or missing notes. If
+	// empty, these links are removed.
+	UnpublishedLinkText string
+}
+
+// isPublished checks if a wiki link references a published note. Links to
+// other files, e.g. images, are not checked.
+func (r *Renderer) isPublished(link string) bool {
+	if _, found := r.Published[noteFilename(link)]; found {
+		return true
+	}
+	return isMedia(link)
+}
+
+// noteFilename returns the filename of the note a wiki link references, which
+// is the link itself with an optional .md suffix. Titles can contain dots,
+// e.g. [[202010 Go 1.16]].
+func noteFilename(link string) string {
+	if strings.HasSuffix(link, ".md") {
+		return link
+	}
+	return link + ".md"
+}
+
+// isMedia checks if a wiki link references a file with a known media type
+// instead of a note.
+func isMedia(link string) bool {
+	extension := path.Ext(link)
+	return extension != "" && extension != ".md" && mime.TypeByExtension(extension) != ""
+}
+
+// unpublishedLink returns the html replacing a link to an unpublished note.
+func (r *Renderer) unpublishedLink() string {
+	if r.UnpublishedLinkText == "" {
+		return ""
+	}
+	return `<span class="unpublished-link">` + html.EscapeString(r.UnpublishedLinkText) + `</span>`
+}
+
+// ToPage converts markdown to the data necessary to render it with a template.
+func (r *Renderer) ToPage(filename string, data []byte) templates.Page {
+	frontMatter, _ := utils.FrontMatter(data)
+	titleLine, html := r.ToContent(data)
+
+	page := computeMetadata(r.BaseURL, r.Routes.Path(filename), filename, frontMatter, titleLine, html)
+	// The html is generated by our own markdown processor from our own files.
+	page.Content = template.HTML(html)
+	page.Name = filename
+	page.Backlinks = r.backlinkList(filename)
+	page.Mentions = r.mentionList(filename)
+	page.Tags = tagList(data)
+	page.NoIndex = utils.HasTag(data, utils.NoIndexTag)
+	page.Build = utils.BuildInformation()
+	return page
+}
+
+// ToContent converts markdown to its title and the rendered html without
+// embedding it into the page template, e.g. for feeds.
+func (r *Renderer) ToContent(data []byte) (string, string) {
+	// Front matter is metadata and not part of the displayed content.
+	_, data = utils.FrontMatter(data)
+
 	// Perform various pre-processing steps on the markdown.
-	markdown := processRawMarkdown(data)
+	markdown := r.processRawMarkdown(data)
 	titleLine := title(markdown)
 
 	// Convert from (processed) markdown to html.
 	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{})
 	html := string(blackfriday.Run([]byte(markdown), blackfriday.WithRenderer(renderer)))
 
-	// Inject rendered html into template and fill variables.
-	// We are intentionally not using html.template here since we
-	// do don't want escaping, etc.
-	bsTemplate, err := ioutil.ReadFile("template.html")
-	if err != nil {
-		log.Warn("Template not found. This should never happen.")
-		return "", errors.New("template not found")
-	}
-	html = strings.ReplaceAll(string(bsTemplate), "{{content}}", html)
-	html = strings.ReplaceAll(html, "{{title}}", titleLine)
-	html = strings.ReplaceAll(html, "{{build}}", utils.BuildInformation())
-	html = strings.ReplaceAll(html, "{{backlinks}}", generateBacklinkHTML(filename))
-
-	return html, nil
+	return titleLine, html
 }
 
 // title uses the first line in markdown as title if available and feasible.
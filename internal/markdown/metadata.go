@@ -0,0 +1,81 @@
+package markdown
+
+import (
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Maximum length of a computed description.
+	descriptionLength = 200
+)
+
+// computeMetadata derives the metadata of a page for previews in chats and
+// search engines. The description is taken from the front matter or, if not
+// available, from the first paragraph of the rendered html. Path is the
+// canonical path of the page.
+func computeMetadata(baseURL string, path string, filename string, frontMatter map[string]string, title string, content string) templates.Page {
+	metadata := templates.Page{
+		Title:       stripTags(title),
+		Description: frontMatter["description"],
+		Canonical:   baseURL + path,
+	}
+
+	if metadata.Description == "" {
+		metadata.Description = firstParagraph(content)
+	}
+
+	rxImage := regexp.MustCompile(`<img[^>]*src="([^"]*)"`)
+	if matches := rxImage.FindStringSubmatch(content); len(matches) > 1 {
+		metadata.Image = utils.AbsoluteURL(baseURL, html.UnescapeString(matches[1]))
+	}
+
+	if date, ok := utils.NoteDate(filename, frontMatter); ok {
+		metadata.Date = date
+	}
+
+	return metadata
+}
+
+// firstParagraph returns the text of the first paragraph which does not
+// consist of tags only, shortened to a reasonable length.
+func firstParagraph(content string) string {
+	rx := regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+	for _, matches := range rx.FindAllStringSubmatch(content, -1) {
+		text := strings.Join(strings.Fields(stripTags(matches[1])), " ")
+		onlyTags := true
+		for _, word := range strings.Fields(text) {
+			if !strings.HasPrefix(word, "#") {
+				onlyTags = false
+				break
+			}
+		}
+		if text == "" || onlyTags {
+			continue
+		}
+		return shorten(text, descriptionLength)
+	}
+	return ""
+}
+
+// shorten cuts text at the last word boundary before the maximum length.
+func shorten(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	shortened := string(runes[:length])
+	if i := strings.LastIndex(shortened, " "); i > 0 {
+		shortened = shortened[:i]
+	}
+	return shortened + "…"
+}
+
+// stripTags removes all html tags and returns the unescaped text.
+func stripTags(s string) string {
+	rx := regexp.MustCompile(`<[^>]*>`)
+	return strings.TrimSpace(html.UnescapeString(rx.ReplaceAllString(s, "")))
+}
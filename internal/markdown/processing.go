@@ -2,6 +2,7 @@ package markdown
 
 import (
 	"fmt"
+	"github.com/mlesniak/markdown/internal/templates"
 	"github.com/mlesniak/markdown/internal/utils"
 	"regexp"
 	"strings"
@@ -12,13 +13,16 @@ const (
 	defaultTitle = "mlesniak.com"
 )
 
+// wikiLinkRegex matches wiki links such as [[202009010824 Zettelkasten]].
+var wikiLinkRegex = regexp.MustCompile(`\[\[(.*?)\]\]`)
+
 // processRawMarkdown performs various conversion steps which are not supported by
 // the markdown processor. In addition, it uses the first line of the file to compute
 // a potential title.
-func processRawMarkdown(rawMarkdown []byte) string {
+func (r *Renderer) processRawMarkdown(rawMarkdown []byte) string {
 	markdown := string(rawMarkdown)
-	markdown = convertTags(markdown)
-	markdown = convertWikiLinks(markdown)
+	markdown = formatTags(markdown)
+	markdown = r.convertWikiLinks(markdown)
 	markdown = convertImages(markdown)
 	return markdown
 }
@@ -37,16 +41,21 @@ func convertImages(markdown string) string {
 	return markdown
 }
 
-// convertWikiLinks converts wikiLinks to normal markdown links.
-func convertWikiLinks(markdown string) string {
-	regex := regexp.MustCompile(`\[\[(.*?)\]\]`)
-	submatches := regex.FindAllStringSubmatch(markdown, -1)
+// convertWikiLinks converts wikiLinks to normal markdown links. Links to notes
+// which are not published are replaced by a placeholder, since neither their
+// filename nor their title must be visible.
+func (r *Renderer) convertWikiLinks(markdown string) string {
+	submatches := wikiLinkRegex.FindAllStringSubmatch(markdown, -1)
 	for _, matches := range submatches {
 		if len(matches) < 2 {
 			continue
 		}
 		fileLinkName := matches[1]
 		wikiLink := matches[0]
+		if !r.isPublished(fileLinkName) {
+			markdown = strings.ReplaceAll(markdown, wikiLink, r.unpublishedLink())
+			continue
+		}
 		// Handle case in which a wikiLink links to a file without a timestamp.
 		filenameParts := strings.SplitN(fileLinkName, " ", 2)
 		var displayedName string
@@ -55,24 +64,63 @@ func convertWikiLinks(markdown string) string {
 		} else {
 			displayedName = filenameParts[1]
 		}
-		if !strings.Contains(fileLinkName, ".") {
-			fileLinkName = fileLinkName + ".md"
+		if _, found := r.Published[noteFilename(fileLinkName)]; found {
+			fileLinkName = noteFilename(fileLinkName)
 		}
-		fileLinkName = strings.ReplaceAll(fileLinkName, " ", "-")
-		markdownLink := fmt.Sprintf(`[%s](/%s)`, displayedName, fileLinkName)
+		markdownLink := fmt.Sprintf(`[%s](%s)`, displayedName, r.Routes.Path(fileLinkName))
 		markdown = strings.ReplaceAll(markdown, wikiLink, markdownLink)
 	}
 
 	return markdown
 }
 
-func convertTags(markdown string) string {
-	linkTags := utils.GetTags([]byte(markdown))
-
-	for _, tag := range linkTags {
-		link := fmt.Sprintf(`<a href="/tag-%s.md" class="tag">%s </a>`, tag[1:], tag)
-		markdown = strings.ReplaceAll(markdown, tag, link)
+// formatTags removes lines containing only tags, usually the block at the end
+// of a note, since all tags are listed below the content, see tagList. Tags
+// within the text are linked to their tag page, hidden tags are removed. Code
+// is kept as it is.
+func formatTags(markdown string) string {
+	code := utils.CodeRanges(markdown)
+	kept := []string{}
+	offset := 0
+	for _, line := range strings.Split(markdown, "\n") {
+		start := offset
+		offset += len(line) + 1
+		if !utils.InRanges(code, start) && isTagLine(line) {
+			continue
+		}
+		kept = append(kept, line)
 	}
+	return utils.ReplaceTags(strings.Join(kept, "\n"), func(tag string, canonical string) string {
+		if utils.IsHiddenTag(canonical) {
+			return ""
+		}
+		return fmt.Sprintf("[%s](%s)", tag, utils.PagePath(utils.TagFilename(canonical, ".md")))
+	})
+}
 
-	return markdown
+// isTagLine checks if a line contains tags and nothing else.
+func isTagLine(line string) bool {
+	found := false
+	rest := utils.ReplaceTags(line, func(tag string, canonical string) string {
+		found = true
+		return ""
+	})
+	return found && strings.TrimSpace(rest) == ""
+}
+
+// tagList returns links to the pages of all tags of a note.
+func tagList(data []byte) []templates.Link {
+	links := []templates.Link{}
+	seen := make(map[string]struct{})
+	for _, tag := range utils.GetTags(data) {
+		if _, found := seen[tag]; found {
+			continue
+		}
+		seen[tag] = struct{}{}
+		links = append(links, templates.Link{
+			Title: tag,
+			Path:  utils.PagePath(utils.TagFilename(tag, ".md")),
+		})
+	}
+	return links
 }
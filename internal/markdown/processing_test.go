@@ -0,0 +1,26 @@
+package markdown
+
+import "testing"
+
+func TestFormatTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"inline tag", "I like #go a lot.", "I like [#go](/tag-go.md) a lot."},
+		{"tag block", "Text.\n\n#public #go", "Text.\n"},
+		{"anchor", "See [intro](#intro).", "See [intro](#intro)."},
+		{"fenced code", "```\n#include <stdio.h>\n```", "```\n#include <stdio.h>\n```"},
+		{"inline code", "Use `#include`.", "Use `#include`."},
+		{"tag line in code", "```\n#define\n#go\n```", "```\n#define\n#go\n```"},
+		{"indented tag line", "Text.\n\n    #go\n\n#go", "Text.\n\n    #go\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatTags(test.markdown); got != test.want {
+				t.Errorf("formatTags(%q) = %q, want %q", test.markdown, got, test.want)
+			}
+		})
+	}
+}
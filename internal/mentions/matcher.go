@@ -0,0 +1,122 @@
+package mentions
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// matcher finds all occurrences of many titles in a single pass over a text,
+// using the Aho-Corasick algorithm. Titles and texts have to be folded, see
+// fold.
+type matcher struct {
+	nodes []node
+	// Lengths of all titles in bytes, by index.
+	lengths []int
+}
+
+type node struct {
+	next map[byte]int
+	// fail is the node of the longest proper suffix which is in the trie.
+	fail int
+	// Indices of all titles ending in this node, including those of its
+	// suffixes.
+	titles []int
+}
+
+func newMatcher(titles []string) *matcher {
+	m := &matcher{nodes: []node{{next: make(map[byte]int)}}}
+	for index, title := range titles {
+		current := 0
+		for i := 0; i < len(title); i++ {
+			next, ok := m.nodes[current].next[title[i]]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, node{next: make(map[byte]int)})
+				m.nodes[current].next[title[i]] = next
+			}
+			current = next
+		}
+		m.nodes[current].titles = append(m.nodes[current].titles, index)
+		m.lengths = append(m.lengths, len(title))
+	}
+
+	// Compute the failure links breadth-first, so the links of all shorter
+	// suffixes are known.
+	queue := []int{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for b, child := range m.nodes[current].next {
+			fail := m.nodes[current].fail
+			for fail != 0 && !m.has(fail, b) {
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[b]; ok {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].titles = append(m.nodes[child].titles, m.nodes[m.nodes[child].fail].titles...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+func (m *matcher) has(n int, b byte) bool {
+	_, ok := m.nodes[n].next[b]
+	return ok
+}
+
+// find calls found with the index, start and end of every occurrence of a
+// title which is a whole word, i.e. not surrounded by letters or digits.
+func (m *matcher) find(text string, found func(title int, start int, end int)) {
+	current := 0
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+		for current != 0 && !m.has(current, b) {
+			current = m.nodes[current].fail
+		}
+		if next, ok := m.nodes[current].next[b]; ok {
+			current = next
+		}
+		for _, title := range m.nodes[current].titles {
+			start, end := i+1-m.lengths[title], i+1
+			if isWord(text, start, end) {
+				found(title, start, end)
+			}
+		}
+	}
+}
+
+// isWord checks if the text between start and end is neither preceded nor
+// followed by a letter or digit.
+func isWord(text string, start int, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// fold lowercases the text for case-insensitive matching. Characters whose
+// lower case has a different length are kept, so that positions in the folded
+// text are valid in the original one.
+func fold(text string) string {
+	bs := []byte(text)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if lower := unicode.ToLower(r); lower != r && utf8.RuneLen(lower) == size {
+			utf8.EncodeRune(bs[i:], lower)
+		}
+		i += size
+	}
+	return string(bs)
+}
@@ -0,0 +1,153 @@
+// Package mentions finds notes which mention the title of another note in
+// plain text without linking to it.
+package mentions
+
+import (
+	"github.com/mlesniak/markdown/internal/backlinks"
+	"github.com/mlesniak/markdown/internal/utils"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// linkRegex matches wiki links and markdown links.
+var linkRegex = regexp.MustCompile(`\[\[.*?\]\]|\[[^\]]*\]\([^)]*\)`)
+
+// Options control which titles are considered.
+type Options struct {
+	// Titles shorter than MinLength characters are ignored.
+	MinLength int
+	// Ignore contains titles which are too common to be meaningful, compared
+	// case-insensitively.
+	Ignore []string
+}
+
+// Mention describes an unlinked mention of the target's title in the source.
+type Mention struct {
+	Source   string
+	Target   string
+	Position int
+	Context  string
+}
+
+// Index contains all unlinked mentions, by target.
+type Index struct {
+	mentions map[string][]Mention
+	lock     sync.RWMutex
+}
+
+var singleton *Index
+
+func init() {
+	singleton = &Index{
+		mentions: make(map[string][]Mention),
+	}
+}
+
+func Get() *Index {
+	return singleton
+}
+
+// Update recomputes all unlinked mentions between the given files.
+func (i *Index) Update(files map[string][]byte, options Options) {
+	mentions := Compute(files, options)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.mentions = make(map[string][]Mention)
+	for _, mention := range mentions {
+		i.mentions[mention.Target] = append(i.mentions[mention.Target], mention)
+	}
+}
+
+// GetMentions returns all unlinked mentions of the file.
+func (i *Index) GetMentions(filename string) []Mention {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.mentions[filename]
+}
+
+// List returns all unlinked mentions, sorted by target and source.
+func (i *Index) List() []Mention {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	list := []Mention{}
+	for _, mentions := range i.mentions {
+		list = append(list, mentions...)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Target != list[b].Target {
+			return list[a].Target < list[b].Target
+		}
+		if list[a].Source != list[b].Source {
+			return list[a].Source < list[b].Source
+		}
+		return list[a].Position < list[b].Position
+	})
+	return list
+}
+
+// Compute finds all unlinked mentions between the given files. A file which
+// links to a note at least once does not mention it unlinked. All titles are
+// searched for at once, so the cost grows with the size of the files, not with
+// the number of files times the number of titles.
+func Compute(files map[string][]byte, options Options) []Mention {
+	ignored := make(map[string]struct{})
+	for _, title := range options.Ignore {
+		ignored[fold(title)] = struct{}{}
+	}
+
+	titles := []string{}
+	targets := []string{}
+	for filename := range files {
+		title := fold(utils.FilenameTitle(filename))
+		if _, found := ignored[title]; found {
+			continue
+		}
+		if title == "" || len([]rune(title)) < options.MinLength {
+			continue
+		}
+		titles = append(titles, title)
+		targets = append(targets, filename)
+	}
+	m := newMatcher(titles)
+
+	mentions := []Mention{}
+	for source, bs := range files {
+		_, data := utils.FrontMatter(bs)
+		markdown := string(data)
+		text := fold(withoutLinks(markdown))
+
+		linked := make(map[string]struct{})
+		for _, link := range backlinks.GetLinks(data) {
+			linked[link] = struct{}{}
+		}
+
+		m.find(text, func(title int, start int, end int) {
+			target := targets[title]
+			if target == source {
+				return
+			}
+			if _, found := linked[target]; found {
+				return
+			}
+			mentions = append(mentions, Mention{
+				Source:   source,
+				Target:   target,
+				Position: start,
+				Context:  backlinks.Context(markdown, start, end),
+			})
+		})
+	}
+	return mentions
+}
+
+// withoutLinks blanks wiki links and markdown links while preserving the
+// positions of all other text.
+func withoutLinks(markdown string) string {
+	return linkRegex.ReplaceAllStringFunc(markdown, func(link string) string {
+		return strings.Repeat(" ", len(link))
+	})
+}
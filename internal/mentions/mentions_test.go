@@ -0,0 +1,57 @@
+package mentions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	files := map[string][]byte{
+		"1 Zettelkasten.md": []byte("# Zettelkasten\n\nA slip box, see Go and Käse.\n"),
+		"2 Slip box.md":     []byte("# Slip box\n\nThe zettelkasten is a [[1 Zettelkasten]].\n"),
+		"3 Käse.md":         []byte("# Käse\n\nAbout KÄSE, not Käsekuchen or Zettelkastens.\n"),
+		"4 Go.md":           []byte("# Go\n\nNo mention of a Slip box in [the Slip box](x).\n"),
+	}
+
+	mentions := Compute(files, Options{MinLength: 3})
+	found := []string{}
+	for _, mention := range mentions {
+		found = append(found, fmt.Sprintf("%s -> %s @%d", mention.Source, mention.Target, mention.Position))
+	}
+	sort.Strings(found)
+	got := strings.Join(found, ", ")
+	want := "1 Zettelkasten.md -> 2 Slip box.md @18, 1 Zettelkasten.md -> 3 Käse.md @39, " +
+		"4 Go.md -> 2 Slip box.md @22"
+	if got != want {
+		t.Errorf("Compute() = %s, want %s", got, want)
+	}
+}
+
+// benchmarkFiles returns notes of about 3KB in short paragraphs, each
+// mentioning two other notes.
+func benchmarkFiles(notes int) map[string][]byte {
+	files := make(map[string][]byte)
+	for i := 0; i < notes; i++ {
+		var text strings.Builder
+		fmt.Fprintf(&text, "# Topic %d\n\nThis relates to topic %d and topic %d.\n\n", i, (i*7)%notes, (i*13)%notes)
+		for text.Len() < 3000 {
+			text.WriteString("Some words about a subject, which are not the title of any note.\n\n")
+		}
+		files[fmt.Sprintf("%012d Topic %d.md", i, i)] = []byte(text.String())
+	}
+	return files
+}
+
+// The time per note should stay roughly the same with more notes.
+func BenchmarkCompute(b *testing.B) {
+	for _, notes := range []int{100, 200, 400, 800} {
+		files := benchmarkFiles(notes)
+		b.Run(fmt.Sprintf("notes=%d", notes), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Compute(files, Options{MinLength: 4})
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package report analyzes the link graph to keep the Zettelkasten healthy.
+package report
+
+import (
+	"github.com/mlesniak/markdown/internal/backlinks"
+	"sort"
+)
+
+// Hub is a note with many incoming links.
+type Hub struct {
+	Note     string
+	Incoming int
+}
+
+// Report lists notes which might need attention.
+type Report struct {
+	// Orphans are notes without incoming links besides the one from the index.
+	Orphans []string
+	// DeadEnds are notes without outgoing links.
+	DeadEnds []string
+	// Hubs are the most linked notes.
+	Hubs []Hub
+	// Unreachable are public notes which can not be reached from the root files.
+	Unreachable []string
+}
+
+// Compute creates the report for the link graph. The root files, the first
+// being the index, are never orphans.
+func Compute(links *backlinks.Graph, rootFiles []string, hubs int) Report {
+	report := Report{
+		Orphans:  []string{},
+		DeadEnds: []string{},
+		Hubs:     []Hub{},
+	}
+
+	roots := make(map[string]struct{})
+	for _, root := range rootFiles {
+		roots[root] = struct{}{}
+	}
+	index := ""
+	if len(rootFiles) > 0 {
+		index = rootFiles[0]
+	}
+
+	for _, note := range links.Notes() {
+		parents := links.Parents(note)
+		if _, root := roots[note]; !root {
+			if len(parents) == 0 || (len(parents) == 1 && parents[0] == index) {
+				report.Orphans = append(report.Orphans, note)
+			}
+		}
+		if len(links.Children(note)) == 0 {
+			report.DeadEnds = append(report.DeadEnds, note)
+		}
+		if len(parents) > 0 {
+			report.Hubs = append(report.Hubs, Hub{Note: note, Incoming: len(parents)})
+		}
+	}
+
+	sort.SliceStable(report.Hubs, func(i, j int) bool {
+		return report.Hubs[i].Incoming > report.Hubs[j].Incoming
+	})
+	if len(report.Hubs) > hubs {
+		report.Hubs = report.Hubs[:hubs]
+	}
+	return report
+}
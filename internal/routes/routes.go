@@ -0,0 +1,350 @@
+// Package routes maps notes to the paths under which they are served. Every
+// note has a canonical path, computed while updating the cache, and older or
+// alternative forms of it which are redirected to the canonical one.
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/mlesniak/markdown/internal/utils"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Mode determines how canonical paths are computed. A slug in the front matter
+// of a note always takes precedence.
+type Mode string
+
+const (
+	// Filename uses the filename, e.g. /202009010824-Zettelkasten.md.
+	Filename Mode = "filename"
+	// ID uses the Zettelkasten ID, e.g. /202009010824.
+	ID Mode = "id"
+	// Title uses the title, e.g. /zettelkasten.
+	Title Mode = "title"
+)
+
+var idRegex = regexp.MustCompile(`^(\d+)( |\.md$)`)
+
+// idPrefixRegex matches paths starting with a Zettelkasten ID, e.g. of
+// renamed notes such as /202009010824-Old-title.md. Paths of other files,
+// e.g. /202009010824-diagram.png, are excluded, see isNotePath.
+var idPrefixRegex = regexp.MustCompile(`^/(\d+)([- ].*)?$`)
+
+// reserved paths are served by other handlers.
+var reserved = map[string]struct{}{
+	"/search": {}, "/graph": {}, "/tags": {}, "/api": {}, "/static": {}, "/download": {}, "/admin": {}, "/dropbox": {},
+}
+
+// Table maps notes to their canonical path and back.
+type Table struct {
+	// Canonical paths by filename.
+	paths map[string]string
+	// Filenames by canonical path and alternative forms.
+	files map[string]string
+	// Redirect targets by arbitrary old paths.
+	redirects map[string]string
+}
+
+// New computes the paths of all notes. Redirects map arbitrary old paths to
+// a new path, URL or filename of a note, in addition to the aliases in the
+// front matter of notes.
+func New(mode Mode, fileBuffers map[string][]byte, redirects map[string]string) *Table {
+	t := &Table{
+		paths:     make(map[string]string),
+		files:     make(map[string]string),
+		redirects: make(map[string]string),
+	}
+
+	// Sorted to resolve conflicts deterministically, i.e. older notes keep
+	// their slug.
+	filenames := []string{}
+	for filename := range fileBuffers {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		frontMatter, _ := utils.FrontMatter(fileBuffers[filename])
+		slug := Slugify(frontMatter["slug"])
+		if slug == "" {
+			slug = defaultSlug(mode, filename)
+		}
+		_, taken := t.files["/"+slug]
+		if _, isReserved := reserved["/"+slug]; taken || isReserved || slug == "" {
+			slug = strings.Trim(slug+"-"+Slugify(strings.TrimSuffix(filename, ".md")), "-")
+		}
+		path := "/" + slug
+		t.paths[filename] = path
+		t.files[path] = filename
+	}
+
+	// Alternative forms are added afterwards, so they never shadow a
+	// canonical path.
+	for _, filename := range filenames {
+		alternatives := []string{
+			utils.PagePath(filename),
+			"/" + filename,
+			strings.TrimSuffix(utils.PagePath(filename), ".md"),
+		}
+		if id, ok := noteID(filename); ok {
+			alternatives = append(alternatives, "/"+id)
+		}
+		for _, alternative := range alternatives {
+			if _, taken := t.files[alternative]; !taken {
+				t.files[alternative] = filename
+			}
+		}
+	}
+
+	for from, to := range redirects {
+		if filename, ok := t.files[to]; ok {
+			to = t.paths[filename]
+		} else if path, ok := t.paths[to]; ok {
+			to = path
+		}
+		t.addRedirect(from, to)
+	}
+	for _, filename := range filenames {
+		frontMatter, _ := utils.FrontMatter(fileBuffers[filename])
+		aliases := strings.Trim(frontMatter["aliases"], "[]")
+		for _, alias := range utils.SplitList(aliases) {
+			t.addRedirect(alias, t.paths[filename])
+		}
+	}
+	return t
+}
+
+// addRedirect adds a redirect unless the path belongs to a note.
+func (t *Table) addRedirect(from string, to string) {
+	if !strings.HasPrefix(from, "/") {
+		from = "/" + from
+	}
+	if _, taken := t.files[from]; taken {
+		return
+	}
+	t.redirects[from] = to
+}
+
+// NewEmpty returns a table without notes.
+func NewEmpty() *Table {
+	return New(Filename, map[string][]byte{}, map[string]string{})
+}
+
+// GobEncode implements gob.GobEncoder, so that the table can be persisted.
+func (t *Table) GobEncode() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode([]map[string]string{t.paths, t.files, t.redirects})
+	return buffer.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (t *Table) GobDecode(data []byte) error {
+	var maps []map[string]string
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&maps); err != nil {
+		return err
+	}
+	if len(maps) != 3 {
+		return errors.New("invalid route table")
+	}
+	t.paths, t.files, t.redirects = maps[0], maps[1], maps[2]
+	return nil
+}
+
+func defaultSlug(mode Mode, filename string) string {
+	switch mode {
+	case ID:
+		if id, ok := noteID(filename); ok {
+			return id
+		}
+		return Slugify(utils.FilenameTitle(filename))
+	case Title:
+		return Slugify(utils.FilenameTitle(filename))
+	default:
+		return strings.TrimPrefix(utils.PagePath(filename), "/")
+	}
+}
+
+// noteID returns the Zettelkasten ID of a filename, e.g. 202009010824.
+func noteID(filename string) (string, bool) {
+	matches := idRegex.FindStringSubmatch(filename)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// Slugify converts a text to lower case letters and digits separated by
+// single dashes, e.g. "Go & Rust" to go-rust.
+func Slugify(text string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return sb.String()
+}
+
+// ID returns the Zettelkasten ID of a note or, if it has none, its canonical
+// path without the leading slash. The ID is resolved by Resolve.
+func (t *Table) ID(filename string) string {
+	if id, ok := noteID(filename); ok {
+		return id
+	}
+	return strings.TrimPrefix(t.Path(filename), "/")
+}
+
+// Path returns the canonical path of a note. Unknown files, e.g. images, keep
+// their filename.
+func (t *Table) Path(filename string) string {
+	if path, ok := t.paths[filename]; ok {
+		return path
+	}
+	return utils.PagePath(filename)
+}
+
+// Resolve returns the note of a path and whether the path is its canonical
+// one. Otherwise, it should be redirected to the canonical path. Unknown paths
+// starting with the ID of a note, e.g. after renaming it, resolve to it.
+func (t *Table) Resolve(path string) (string, bool, bool) {
+	filename, ok := t.files[path]
+	if !ok {
+		matches := idPrefixRegex.FindStringSubmatch(path)
+		if matches == nil || !isNotePath(path) {
+			return "", false, false
+		}
+		filename, ok = t.files["/"+matches[1]]
+		if !ok {
+			return "", false, false
+		}
+	}
+	return filename, t.paths[filename] == path, true
+}
+
+// isNotePath checks if a path can belong to a note, i.e. it has no extension,
+// ends with .md or has an extension without media type, e.g. /202010-Go-1.16.
+func isNotePath(p string) bool {
+	extension := path.Ext(p)
+	return extension == "" || extension == ".md" || mime.TypeByExtension(extension) == ""
+}
+
+// Redirect returns the target of a redirected path, see New.
+func (t *Table) Redirect(path string) (string, bool) {
+	to, ok := t.redirects[path]
+	return to, ok
+}
+
+// ParseRedirects parses redirects with one redirect per line, consisting of
+// the old path and its target separated by whitespace. Empty lines and lines
+// starting with # are ignored.
+func ParseRedirects(r io.Reader) (map[string]string, error) {
+	redirects := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid redirect: %s", line)
+		}
+		// Targets can be filenames containing spaces.
+		redirects[fields[0]] = strings.TrimSpace(line[len(fields[0]):])
+	}
+	return redirects, scanner.Err()
+}
+
+// Suggest returns up to max notes whose path or title are similar to the
+// path, e.g. for misspelled links, or whose ID starts with the digits of the
+// path. The closest notes come first.
+func (t *Table) Suggest(path string, max int) []string {
+	query := Slugify(strings.TrimSuffix(strings.TrimPrefix(path, "/"), ".md"))
+	if query == "" {
+		return []string{}
+	}
+	digits := query
+	if i := strings.IndexFunc(query, func(r rune) bool { return !unicode.IsDigit(r) }); i >= 0 {
+		digits = query[:i]
+	}
+
+	distances := make(map[string]int)
+	for filename, canonical := range t.paths {
+		distance := -1
+		if id, ok := noteID(filename); ok && len(digits) >= 4 && strings.HasPrefix(id, digits) {
+			distance = 0
+		}
+		for _, candidate := range []string{Slugify(canonical), Slugify(utils.FilenameTitle(filename)), Slugify(filename)} {
+			d := editDistance(query, candidate)
+			if d <= len([]rune(query))/3 && (distance < 0 || d < distance) {
+				distance = d
+			}
+		}
+		if distance >= 0 {
+			distances[filename] = distance
+		}
+	}
+
+	filenames := []string{}
+	for filename := range distances {
+		filenames = append(filenames, filename)
+	}
+	sort.Slice(filenames, func(i, j int) bool {
+		if distances[filenames[i]] != distances[filenames[j]] {
+			return distances[filenames[i]] < distances[filenames[j]]
+		}
+		return filenames[i] < filenames[j]
+	})
+	if len(filenames) > max {
+		filenames = filenames[:max]
+	}
+	return filenames
+}
+
+// editDistance computes the Levenshtein distance of two strings.
+func editDistance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+func minimum(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
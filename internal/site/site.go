@@ -0,0 +1,60 @@
+// Package site holds the snapshot of the published site computed by the last
+// cache update. Request handlers read the current snapshot while the next one
+// is built, which then replaces it as a whole.
+package site
+
+import (
+	"github.com/mlesniak/markdown/internal/backlinks"
+	"github.com/mlesniak/markdown/internal/graph"
+	"github.com/mlesniak/markdown/internal/routes"
+	"github.com/mlesniak/markdown/internal/tags"
+	"github.com/mlesniak/markdown/internal/templates"
+	"sync"
+)
+
+// Snapshot contains everything derived from the published notes.
+type Snapshot struct {
+	// Links between published notes.
+	Links *backlinks.Graph
+	// Graph of notes and tags.
+	Graph *graph.Graph
+	// Routes contains the paths of all notes.
+	Routes *routes.Table
+	// Tags of all notes.
+	Tags *tags.Index
+	// Notes contains the title, date and description of all notes by
+	// filename.
+	Notes map[string]templates.Link
+	// Unreachable contains public notes which can not be reached from the
+	// root files, hence are not published.
+	Unreachable []string
+}
+
+// New returns an empty snapshot.
+func New() *Snapshot {
+	return &Snapshot{
+		Links:       backlinks.NewGraph(),
+		Graph:       graph.New([]graph.Node{}, []graph.Edge{}),
+		Routes:      routes.NewEmpty(),
+		Tags:        tags.NewIndex(),
+		Notes:       make(map[string]templates.Link),
+		Unreachable: []string{},
+	}
+}
+
+var current = New()
+var lock sync.RWMutex
+
+// Get returns the current snapshot.
+func Get() *Snapshot {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+// Set replaces the current snapshot.
+func Set(snapshot *Snapshot) {
+	lock.Lock()
+	defer lock.Unlock()
+	current = snapshot
+}
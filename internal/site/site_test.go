@@ -0,0 +1,36 @@
+package site
+
+import (
+	"github.com/mlesniak/markdown/internal/templates"
+	"sync"
+	"testing"
+)
+
+// TestConcurrency is meant to be run with -race.
+func TestConcurrency(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := New()
+				snapshot.Notes["a.md"] = templates.Link{Title: "A"}
+				Set(snapshot)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := Get()
+				_ = snapshot.Notes["a.md"]
+				snapshot.Links.Incoming("a.md")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if link := Get().Notes["a.md"]; link.Title != "A" {
+		t.Errorf("Notes[a.md] = %v, want title A", link)
+	}
+}
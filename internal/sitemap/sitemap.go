@@ -0,0 +1,64 @@
+// Package sitemap generates sitemap.xml and robots.txt for search engines.
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// URL is a single page in the sitemap.
+type URL struct {
+	// Path is the absolute path of the page, e.g. /tag-go.md.
+	Path    string
+	LastMod time.Time
+}
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+// Generate renders the sitemap for all urls, sorted by path.
+func Generate(baseURL string, urls []URL) ([]byte, error) {
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].Path < urls[j].Path
+	})
+
+	set := urlSet{}
+	for _, url := range urls {
+		entry := urlEntry{Loc: baseURL + url.Path}
+		if !url.LastMod.IsZero() {
+			entry.LastMod = url.LastMod.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+
+	bs, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), bs...), nil
+}
+
+// Robots renders a robots.txt which allows everything besides the disallowed
+// paths and references the sitemap.
+func Robots(baseURL string, disallow []string) []byte {
+	buf := strings.Builder{}
+	buf.WriteString("User-agent: *\n")
+	if len(disallow) == 0 {
+		buf.WriteString("Disallow:\n")
+	}
+	for _, path := range disallow {
+		buf.WriteString(fmt.Sprintf("Disallow: %s\n", path))
+	}
+	buf.WriteString(fmt.Sprintf("\nSitemap: %s/sitemap.xml\n", baseURL))
+	return []byte(buf.String())
+}
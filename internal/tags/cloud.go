@@ -0,0 +1,52 @@
+package tags
+
+import (
+	"github.com/mlesniak/markdown/internal/templates"
+	"github.com/mlesniak/markdown/internal/utils"
+	"math"
+	"sort"
+)
+
+const (
+	// Number of different sizes in the tag cloud.
+	cloudWeights = 5
+)
+
+// Links returns links to the pages of all tags with their number of notes and
+// their weight in the tag cloud. The links are sorted by name or, if byCount
+// is set, by descending number of notes.
+func (i *Index) Links(byCount bool) []templates.Link {
+	links := []templates.Link{}
+	min, max := math.MaxInt32, 0
+	for _, tag := range i.Tags() {
+		count := len(i.notes[tag])
+		if count < min {
+			min = count
+		}
+		if count > max {
+			max = count
+		}
+		links = append(links, templates.Link{
+			Title: tag,
+			Path:  utils.PagePath(utils.TagFilename(tag, ".md")),
+			Count: count,
+		})
+	}
+
+	// Weights grow logarithmically, otherwise a few frequent tags dwarf all
+	// others.
+	for j := range links {
+		links[j].Weight = 1
+		if max > min {
+			ratio := math.Log(float64(links[j].Count)/float64(min)) / math.Log(float64(max)/float64(min))
+			links[j].Weight = 1 + int(math.Round(ratio*(cloudWeights-1)))
+		}
+	}
+
+	if byCount {
+		sort.SliceStable(links, func(a, b int) bool {
+			return links[a].Count > links[b].Count
+		})
+	}
+	return links
+}
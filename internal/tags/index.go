@@ -0,0 +1,164 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/mlesniak/markdown/internal/utils"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Index maps tags to notes. Notes tagged with a hierarchical tag such as
+// #lang/go belong to all its ancestors, i.e. #lang, as well.
+type Index struct {
+	// Notes by tag, including those of descendant tags.
+	notes map[string]map[string]struct{}
+	// Child tags by tag.
+	children map[string]map[string]struct{}
+	// Direct tags by note.
+	tags map[string][]string
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{
+		notes:    make(map[string]map[string]struct{}),
+		children: make(map[string]map[string]struct{}),
+		tags:     make(map[string][]string),
+	}
+}
+
+// Add adds the note to all its (canonical) tags and their ancestors.
+func (i *Index) Add(filename string, tags []string) {
+	for _, tag := range tags {
+		if !contains(i.tags[filename], tag) {
+			i.tags[filename] = append(i.tags[filename], tag)
+		}
+		i.add(tag, filename)
+		child := tag
+		for _, parent := range utils.ParentTags(tag) {
+			i.add(parent, filename)
+			if i.children[parent] == nil {
+				i.children[parent] = make(map[string]struct{})
+			}
+			i.children[parent][child] = struct{}{}
+			child = parent
+		}
+	}
+}
+
+func (i *Index) add(tag string, filename string) {
+	if i.notes[tag] == nil {
+		i.notes[tag] = make(map[string]struct{})
+	}
+	i.notes[tag][filename] = struct{}{}
+}
+
+// Tags returns all tags, sorted.
+func (i *Index) Tags() []string {
+	return sortedKeys(i.notes)
+}
+
+// Notes returns all notes of the tag and its descendants, sorted.
+func (i *Index) Notes(tag string) []string {
+	return sortedSet(i.notes[tag])
+}
+
+// NoteTags returns the tags of a note, without ancestors, in the order of
+// their appearance.
+func (i *Index) NoteTags(filename string) []string {
+	if tags, ok := i.tags[filename]; ok {
+		return tags
+	}
+	return []string{}
+}
+
+// Children returns the direct child tags, e.g. #lang/go for #lang, sorted.
+func (i *Index) Children(tag string) []string {
+	return sortedSet(i.children[tag])
+}
+
+// Map returns all notes by tag.
+func (i *Index) Map() map[string][]string {
+	m := make(map[string][]string)
+	for tag := range i.notes {
+		m[tag] = i.Notes(tag)
+	}
+	return m
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]map[string]struct{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	keys := []string{}
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Find returns the tag of a tag page, e.g. #lang/go for tag-lang--go.md.
+// The suffix is optional.
+func (i *Index) Find(filename string) (string, bool) {
+	filename = strings.TrimSuffix(filename, ".md")
+	for tag := range i.notes {
+		if utils.TagFilename(tag, "") == filename {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// FindAlternative returns the tag of an older form of a tag page or feed,
+// e.g. tag-Go.md from before tags were case-insensitive or the page of an
+// alias, which is redirected to the current one.
+func (i *Index) FindAlternative(filename string) (string, bool) {
+	name := strings.TrimSuffix(filename, path.Ext(filename))
+	if !strings.HasPrefix(name, "tag-") {
+		return "", false
+	}
+	tag := utils.CanonicalTag("#" + strings.ReplaceAll(strings.TrimPrefix(name, "tag-"), "--", "/"))
+	if _, found := i.notes[tag]; !found {
+		return "", false
+	}
+	return tag, true
+}
+
+// GobEncode implements gob.GobEncoder, so that the index can be persisted.
+// Only the direct tags are stored, everything else is derived from them.
+func (i *Index) GobEncode() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(i.tags)
+	return buffer.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (i *Index) GobDecode(data []byte) error {
+	tags := make(map[string][]string)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tags); err != nil {
+		return err
+	}
+	*i = *NewIndex()
+	for filename, noteTags := range tags {
+		i.Add(filename, noteTags)
+	}
+	return nil
+}
@@ -1,55 +1,127 @@
 package tags
 
 import (
-	"fmt"
-	"github.com/labstack/echo/v4"
-	"github.com/mlesniak/markdown/internal/markdown"
+	"github.com/mlesniak/markdown/internal/templates"
 	"github.com/mlesniak/markdown/internal/utils"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-func GenerateTagPage(log echo.Logger, tag string, filenames []string) []byte {
-	titlesFilenames := make(map[string]string)
-	for _, filename := range filenames {
-		parts := strings.SplitN(filename, " ", 2)
-		var titleName string
-		if len(parts) < 2 {
-			titleName = parts[0]
-		} else {
-			titleName = parts[1]
-		}
-		// Remove .md suffix
-		titleName = titleName[:len(titleName)-3]
-		titlesFilenames[titleName] = filename
+const (
+	// Number of notes on a single tag page.
+	pageSize = 20
+
+	// Sort orders of tag pages, by title or newest first.
+	SortTitle = "title"
+	SortDate  = "date"
+)
+
+// Listing is a single page of the notes of a tag.
+type Listing struct {
+	Tag   string           `json:"tag"`
+	Sort  string           `json:"sort"`
+	Page  int              `json:"page"`
+	Pages int              `json:"pages"`
+	Notes []templates.Link `json:"notes"`
+	// Tags are the child tags with the number of their notes.
+	Tags []templates.Link `json:"tags"`
+}
+
+// List returns the requested page of all notes of the tag and its
+// descendants. Summaries contain the title, date and description of all
+// notes. An unknown sort order falls back to SortTitle, ok is false if the
+// page does not exist.
+func (i *Index) List(tag string, summaries map[string]templates.Link, sortBy string, page int) (Listing, bool) {
+	if sortBy != SortDate {
+		sortBy = SortTitle
 	}
 
-	// Get list and sort.
-	titles := []string{}
-	for k, _ := range titlesFilenames {
-		titles = append(titles, k)
+	notes := []templates.Link{}
+	for _, filename := range i.Notes(tag) {
+		summary, found := summaries[filename]
+		if !found {
+			continue
+		}
+		notes = append(notes, summary)
 	}
-	sort.Slice(titles, func(i, j int) bool {
-		return strings.ToLower(titles[i]) < strings.ToLower(titles[j])
+	sort.SliceStable(notes, func(a, b int) bool {
+		if sortBy == SortDate && !notes[a].Date.Equal(notes[b].Date) {
+			return notes[a].Date.After(notes[b].Date)
+		}
+		return strings.ToLower(notes[a].Title) < strings.ToLower(notes[b].Title)
 	})
 
-	tags := strings.Builder{}
-	for _, title := range titles {
-		displayTitle := utils.AutoCaptialize(title)
+	pages := (len(notes) + pageSize - 1) / pageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 1 || page > pages {
+		return Listing{}, false
+	}
+	end := page * pageSize
+	if end > len(notes) {
+		end = len(notes)
+	}
 
-		name := titlesFilenames[title]
-		link := fmt.Sprintf(`- <a href="/%s">%s</a>`, name, displayTitle)
-		tags.WriteString("\n")
-		tags.WriteString(link)
+	children := []templates.Link{}
+	for _, child := range i.Children(tag) {
+		children = append(children, templates.Link{
+			Title: child,
+			Path:  utils.PagePath(utils.TagFilename(child, ".md")),
+			Count: len(i.Notes(child)),
+		})
 	}
-	content := tags.String()
 
-	// Create dynamic markdown.
-	md := []byte(fmt.Sprintf("# Articles tagged %s\n\n%s", tag[1:], content))
+	return Listing{
+		Tag:   tag,
+		Sort:  sortBy,
+		Page:  page,
+		Pages: pages,
+		Notes: notes[(page-1)*pageSize : end],
+		Tags:  children,
+	}, true
+}
+
+// Render renders the listing as tag page.
+func (l Listing) Render(baseURL string) ([]byte, error) {
+	return templates.Get().Render(templates.Tag, templates.Page{
+		Title:       "Articles tagged " + l.Tag[1:],
+		Description: "All articles tagged " + l.Tag,
+		Canonical:   baseURL + l.path(l.Page),
+		Notes:       l.Notes,
+		Tags:        l.Tags,
+		Query:       l.Sort,
+		Pagination: templates.Pagination{
+			Page:     l.Page,
+			Pages:    l.Pages,
+			Previous: l.neighbor(l.Page - 1),
+			Next:     l.neighbor(l.Page + 1),
+		},
+		Build: utils.BuildInformation(),
+	})
+}
 
-	html, _ := markdown.ToHTML(log, "", md)
-	html = strings.ReplaceAll(html, "{{title}}", tag)
-	html = strings.ReplaceAll(html, "{{backlinks}}", "")
+// path returns the path of a page of the listing with its sort order.
+func (l Listing) path(page int) string {
+	query := url.Values{}
+	if l.Sort != SortTitle {
+		query.Set("sort", l.Sort)
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	path := utils.PagePath(utils.TagFilename(l.Tag, ".md"))
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
+}
 
-	return []byte(html)
+func (l Listing) neighbor(page int) string {
+	if page < 1 || page > l.Pages {
+		return ""
+	}
+	return l.path(page)
 }
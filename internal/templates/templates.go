@@ -0,0 +1,222 @@
+// Package templates renders all html pages. Every page kind has its own
+// template which is combined with the base layout and the shared partials.
+// Templates are parsed once at startup.
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Kind describes the different kinds of pages, each having its own template.
+type Kind string
+
+const (
+	Note     Kind = "note"
+	Tag      Kind = "tag"
+	Index    Kind = "index"
+	Search   Kind = "search"
+	NotFound Kind = "404"
+	Graph    Kind = "graph"
+	Report   Kind = "report"
+	Tags     Kind = "tags"
+)
+
+var kinds = []Kind{Note, Tag, Index, Search, NotFound, Graph, Report, Tags}
+
+// Link references another page.
+type Link struct {
+	Title string `json:"title"`
+	// Path is the absolute path of the page, e.g. /202009010520-index.md.
+	// Empty if the page is not published.
+	Path string `json:"path"`
+	// Count is an optional number describing the page, e.g. incoming links.
+	Count int `json:"count,omitempty"`
+	// Weight is the relative size of a tag in the tag cloud, from 1 to 5.
+	Weight int `json:"weight,omitempty"`
+	// Date is the optional publication date of a note, omitted in JSON if
+	// unknown.
+	Date time.Time `json:"-"`
+	// Description is an optional excerpt of a note.
+	Description string `json:"description,omitempty"`
+}
+
+// MarshalJSON adds the date of the link, if known.
+func (l Link) MarshalJSON() ([]byte, error) {
+	type link Link
+	var date *time.Time
+	if !l.Date.IsZero() {
+		date = &l.Date
+	}
+	return json.Marshal(struct {
+		link
+		Date *time.Time `json:"date,omitempty"`
+	}{link(l), date})
+}
+
+// Pagination describes the current page of a long list. Previous and Next
+// are the paths of the neighboring pages, if any.
+type Pagination struct {
+	Page     int
+	Pages    int
+	Previous string
+	Next     string
+}
+
+// Section is a titled list of links, e.g. in reports.
+type Section struct {
+	Title       string
+	Description string
+	Notes       []Link
+}
+
+// Backlink references a page linking to the current page.
+type Backlink struct {
+	Link
+	// Excerpts contain the rendered context of each link in the referencing page.
+	Excerpts []template.HTML
+}
+
+// Page contains all data available to templates.
+type Page struct {
+	// Name is the filename of a note, e.g. to request its local graph.
+	Name        string
+	Title       string
+	Description string
+	// Canonical is the absolute URL of the page.
+	Canonical string
+	// Image is the absolute URL of the first image, if any.
+	Image string
+	Date  time.Time
+	// Content is the rendered markdown and trusted by definition.
+	Content   template.HTML
+	Backlinks []Backlink
+	// Mentions reference pages mentioning the title without linking to it.
+	Mentions []Backlink
+	// Notes lists pages, e.g. on tag pages or search results.
+	Notes []Link
+	// Tags lists tags, e.g. the tags of a note or the child tags on tag pages,
+	// with their number of notes.
+	Tags []Link
+	// TagCloud contains all tags with their weight.
+	TagCloud []Link
+	// NoIndex excludes the page from search engines.
+	NoIndex bool
+	// Query contains the search query or the sort order of tag pages and the
+	// tag index.
+	Query string
+	// Pagination of Notes, with zero pages if not paginated.
+	Pagination Pagination
+	// Sections of a report.
+	Sections []Section
+	Build    string
+}
+
+// Templates contains the parsed templates for all page kinds.
+type Templates struct {
+	pages map[Kind]*template.Template
+}
+
+var singleton *Templates
+var lock sync.RWMutex
+
+// Init parses all templates in the file system. It contains the base layout
+// (layout.html), partials (partials/*.html) and one template per page kind,
+// e.g. note.html.
+func Init(fsys fs.FS) error {
+	t, err := parse(fsys)
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	singleton = t
+	return nil
+}
+
+// Get returns the templates parsed by Init.
+func Get() *Templates {
+	lock.RLock()
+	defer lock.RUnlock()
+	if singleton == nil {
+		panic("templates not initialized")
+	}
+	return singleton
+}
+
+func parse(fsys fs.FS) (*Templates, error) {
+	partials, err := fs.Glob(fsys, "partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{pages: make(map[Kind]*template.Template)}
+	for _, kind := range kinds {
+		files := append([]string{"layout.html"}, partials...)
+		files = append(files, string(kind)+".html")
+		page, err := template.New(string(kind)).Funcs(functions).ParseFS(fsys, files...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template %s: %s", kind, err)
+		}
+		t.pages[kind] = page
+	}
+	return t, nil
+}
+
+var functions = template.FuncMap{
+	// comment emits an html comment, which html/template strips otherwise.
+	"comment": func(text string) template.HTML {
+		return template.HTML("<!-- " + html.EscapeString(text) + " -->")
+	},
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}
+
+// Render renders the page using the template of the given kind.
+func (t *Templates) Render(kind Kind, page Page) ([]byte, error) {
+	tmpl, ok := t.pages[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown page kind: %s", kind)
+	}
+
+	buf := bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(&buf, "layout", page); err != nil {
+		return nil, fmt.Errorf("unable to render template %s: %s", kind, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONLD returns the page as schema.org Article. Inside a script tag of type
+// application/ld+json html/template encodes it as JSON.
+func (p Page) JSONLD() interface{} {
+	article := struct {
+		Context          string `json:"@context"`
+		Type             string `json:"@type"`
+		Headline         string `json:"headline"`
+		Description      string `json:"description,omitempty"`
+		URL              string `json:"url"`
+		MainEntityOfPage string `json:"mainEntityOfPage"`
+		Image            string `json:"image,omitempty"`
+		DatePublished    string `json:"datePublished,omitempty"`
+	}{
+		Context:          "https://schema.org",
+		Type:             "Article",
+		Headline:         p.Title,
+		Description:      p.Description,
+		URL:              p.Canonical,
+		MainEntityOfPage: p.Canonical,
+		Image:            p.Image,
+	}
+	if !p.Date.IsZero() {
+		article.DatePublished = p.Date.Format(time.RFC3339)
+	}
+	return article
+}
@@ -0,0 +1,114 @@
+// Renders the note graph into every element with class graph. The element's
+// data-src attribute references the JSON graph, see /api/graph.json. The layout
+// is computed with a simple force simulation, without external dependencies.
+(function () {
+    "use strict";
+
+    var svgNS = "http://www.w3.org/2000/svg";
+    var width = 800;
+    var height = 500;
+
+    function layout(graph) {
+        var nodes = graph.nodes;
+        var index = {};
+        nodes.forEach(function (node, i) {
+            index[node.id] = i;
+            node.x = width / 2 + Math.cos(i) * width / 4 * Math.random();
+            node.y = height / 2 + Math.sin(i) * height / 4 * Math.random();
+        });
+        var edges = graph.edges.map(function (edge) {
+            return {source: nodes[index[edge.source]], target: nodes[index[edge.target]], kind: edge.kind};
+        });
+
+        var k = Math.sqrt(width * height / Math.max(nodes.length, 1)) * 0.5;
+        for (var step = 0; step < 300; step++) {
+            var temperature = 10 * (1 - step / 300);
+            nodes.forEach(function (node) {
+                node.dx = (width / 2 - node.x) * 0.01;
+                node.dy = (height / 2 - node.y) * 0.01;
+            });
+            // Repulsion between all nodes.
+            for (var i = 0; i < nodes.length; i++) {
+                for (var j = i + 1; j < nodes.length; j++) {
+                    var dx = nodes[i].x - nodes[j].x;
+                    var dy = nodes[i].y - nodes[j].y;
+                    var distance = Math.max(Math.sqrt(dx * dx + dy * dy), 0.01);
+                    var force = k * k / distance / distance;
+                    nodes[i].dx += dx * force;
+                    nodes[i].dy += dy * force;
+                    nodes[j].dx -= dx * force;
+                    nodes[j].dy -= dy * force;
+                }
+            }
+            // Attraction along edges.
+            edges.forEach(function (edge) {
+                var dx = edge.target.x - edge.source.x;
+                var dy = edge.target.y - edge.source.y;
+                var distance = Math.max(Math.sqrt(dx * dx + dy * dy), 0.01);
+                var force = distance / k;
+                edge.source.dx += dx / distance * force;
+                edge.source.dy += dy / distance * force;
+                edge.target.dx -= dx / distance * force;
+                edge.target.dy -= dy / distance * force;
+            });
+            nodes.forEach(function (node) {
+                var length = Math.max(Math.sqrt(node.dx * node.dx + node.dy * node.dy), 0.01);
+                node.x += node.dx / length * Math.min(length, temperature);
+                node.y += node.dy / length * Math.min(length, temperature);
+                node.x = Math.min(width - 20, Math.max(20, node.x));
+                node.y = Math.min(height - 20, Math.max(20, node.y));
+            });
+        }
+        return edges;
+    }
+
+    function element(name, attributes) {
+        var e = document.createElementNS(svgNS, name);
+        Object.keys(attributes).forEach(function (key) {
+            e.setAttribute(key, attributes[key]);
+        });
+        return e;
+    }
+
+    function render(container, graph, current) {
+        if (graph.nodes.length < 2) {
+            return;
+        }
+        var edges = layout(graph);
+        var svg = element("svg", {viewBox: "0 0 " + width + " " + height, width: "100%"});
+        edges.forEach(function (edge) {
+            svg.appendChild(element("line", {
+                x1: edge.source.x, y1: edge.source.y, x2: edge.target.x, y2: edge.target.y,
+                stroke: edge.kind === "tag" ? "#eee" : "#ccc"
+            }));
+        });
+        graph.nodes.forEach(function (node) {
+            var link = element("a", {href: node.path});
+            var radius = 4 + Math.min(node.incoming, 8);
+            var fill = node.kind === "tag" ? "darkgray" : "#00aa00";
+            if (node.id === current) {
+                fill = "#333";
+            }
+            link.appendChild(element("circle", {cx: node.x, cy: node.y, r: radius, fill: fill}));
+            var label = element("text", {x: node.x + radius + 2, y: node.y + 4, "font-size": 11, fill: "#555"});
+            label.textContent = node.title;
+            link.appendChild(label);
+            svg.appendChild(link);
+        });
+        container.appendChild(svg);
+    }
+
+    document.querySelectorAll(".graph").forEach(function (container) {
+        var src = container.getAttribute("data-src");
+        var current = new URL(src, window.location.href).searchParams.get("note");
+        fetch(src)
+            .then(function (response) {
+                return response.ok ? response.json() : null;
+            })
+            .then(function (graph) {
+                if (graph) {
+                    render(container, graph, current);
+                }
+            });
+    });
+})();
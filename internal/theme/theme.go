@@ -0,0 +1,55 @@
+// Package theme provides the templates and static assets of the site. A theme
+// is a directory containing a templates/ and a static/ directory. The default
+// theme is embedded into the binary, so the server runs from any directory.
+package theme
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+//go:embed default
+var defaultTheme embed.FS
+
+// Theme contains the file systems of a theme.
+type Theme struct {
+	Name      string
+	Templates fs.FS
+	Static    fs.FS
+}
+
+// Load returns the theme in the given directory or the embedded default theme
+// if the directory is empty.
+func Load(directory string) (Theme, error) {
+	if directory == "" {
+		root, _ := fs.Sub(defaultTheme, "default")
+		return fromFS("default", root)
+	}
+
+	info, err := os.Stat(directory)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme not found: %s", err)
+	}
+	if !info.IsDir() {
+		return Theme{}, fmt.Errorf("theme is not a directory: %s", directory)
+	}
+	return fromFS(directory, os.DirFS(directory))
+}
+
+func fromFS(name string, root fs.FS) (Theme, error) {
+	templates, err := fs.Sub(root, "templates")
+	if err != nil {
+		return Theme{}, err
+	}
+	static, err := fs.Sub(root, "static")
+	if err != nil {
+		return Theme{}, err
+	}
+	return Theme{
+		Name:      name,
+		Templates: templates,
+		Static:    static,
+	}, nil
+}
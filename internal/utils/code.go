@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// fenceRegex matches the opening line of a fenced code block.
+var fenceRegex = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// listRegex matches the first line of a list item.
+var listRegex = regexp.MustCompile(`^ {0,3}([-*+]|\d+[.)])(\s|$)`)
+
+// CodeRanges returns the start and end of all fenced and indented code blocks
+// and inline code spans in markdown, sorted, whose content is not text, e.g.
+// #include in C.
+func CodeRanges(markdown string) [][]int {
+	ranges := [][]int{}
+	fence := ""
+	fenceStart := 0
+	blank, indented, list := true, false, false
+
+	offset := 0
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		start := offset
+		offset += len(line)
+		text := strings.TrimRight(line, "\r\n")
+
+		if fence != "" {
+			if closing := strings.TrimLeft(text, " "); strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]+" ") == "" {
+				ranges = append(ranges, []int{fenceStart, offset})
+				fence = ""
+			}
+			continue
+		}
+		if matches := fenceRegex.FindStringSubmatch(text); matches != nil {
+			fence = matches[1]
+			fenceStart = start
+			blank, indented, list = false, false, false
+			continue
+		}
+
+		isBlank := strings.TrimSpace(text) == ""
+		isIndented := strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t")
+		switch {
+		case isBlank:
+		case isIndented && !list && (blank || indented):
+			// Indented code can not interrupt a paragraph and lines indented
+			// in lists belong to the list item.
+			ranges = append(ranges, []int{start, offset})
+			indented = true
+		case isIndented:
+			ranges = append(ranges, codeSpans(text, start)...)
+		default:
+			list = listRegex.MatchString(text)
+			indented = false
+			ranges = append(ranges, codeSpans(text, start)...)
+		}
+		blank = isBlank
+	}
+	if fence != "" {
+		ranges = append(ranges, []int{fenceStart, len(markdown)})
+	}
+	return ranges
+}
+
+// codeSpans returns the ranges of inline code in a line, which starts at
+// offset in the markdown. A span starts and ends with the same number of
+// backticks.
+func codeSpans(line string, offset int) [][]int {
+	spans := [][]int{}
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		n := backticks(line, i)
+		end := -1
+		for j := i + n; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			m := backticks(line, j)
+			if m == n {
+				end = j + m
+				break
+			}
+			j += m
+		}
+		if end < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, []int{offset + i, offset + end})
+		i = end
+	}
+	return spans
+}
+
+// backticks returns the length of the run of backticks starting at i.
+func backticks(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// InRanges checks if the position is within one of the sorted ranges.
+func InRanges(ranges [][]int, position int) bool {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i][1] > position
+	})
+	return i < len(ranges) && ranges[i][0] <= position
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+)
+
+// FrontMatter splits an optional front matter block of the form
+//
+//	---
+//	key: value
+//	---
+//
+// from the beginning of a markdown file. It returns the parsed keys (lowercased)
+// and the remaining markdown. Files without front matter are returned unchanged.
+func FrontMatter(data []byte) (map[string]string, []byte) {
+	values := make(map[string]string)
+
+	normalized := bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalized, []byte("---\n")) {
+		return values, data
+	}
+	// The block ends with a line containing only ---, which is either
+	// followed by the markdown or the end of the file.
+	body := normalized[3:]
+	var rest []byte
+	end := bytes.Index(body, []byte("\n---\n"))
+	switch {
+	case end >= 0:
+		rest = body[end+5:]
+	case bytes.HasSuffix(body, []byte("\n---")):
+		end = len(body) - 4
+		rest = []byte{}
+	default:
+		return values, data
+	}
+	block := string(bytes.TrimPrefix(body[:end], []byte("\n")))
+
+	for _, line := range strings.Split(block, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		values[key] = value
+	}
+
+	return values, rest
+}
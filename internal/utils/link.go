@@ -0,0 +1,22 @@
+package utils
+
+import "strings"
+
+// PagePath returns the absolute URL path of a file, e.g. of tag pages or
+// images. Spaces are replaced by dashes since they are awkward in URLs. Notes
+// use the paths of routes.Table instead.
+func PagePath(filename string) string {
+	return "/" + strings.ReplaceAll(filename, " ", "-")
+}
+
+// AbsoluteURL converts a link found in rendered html to an absolute URL. Links
+// which already contain a scheme or are anchors are returned unchanged.
+func AbsoluteURL(baseURL string, url string) string {
+	if strings.Contains(url, "://") || strings.HasPrefix(url, "mailto:") || strings.HasPrefix(url, "#") {
+		return url
+	}
+	if !strings.HasPrefix(url, "/") {
+		url = "/" + url
+	}
+	return baseURL + url
+}
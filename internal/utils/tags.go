@@ -3,23 +3,203 @@ package utils
 import (
 	"regexp"
 	"strings"
+	"sync"
 )
 
-// GetTags is defined here to prevent cyclic import.
-func GetTags(data []byte) []string {
-	markdown := string(data)
-	regex := regexp.MustCompile(` *(#\w+)`)
-	matches := regex.FindAllString(markdown, -1)
+// tagRegex matches tags such as #go, #lang/go, #c++ or #käse. A tag starts at
+// the beginning of a line or after whitespace or an opening bracket, so
+// anchors in URLs are not treated as tags.
+var tagRegex = regexp.MustCompile(`(^|[\s(\[])(#[\p{L}\p{N}_+\-/]*[\p{L}\p{N}_+])`)
 
-	tags := []string{}
+// colorRegex and numberRegex match tokens which look like tags, but are hex
+// colors such as #fff or #c0ffee or numbers such as #1. Tags which look like
+// colors have to be configured, see SetHexTags.
+var colorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+var numberRegex = regexp.MustCompile(`^#\p{N}+$`)
+
+// System tags control how notes are published. They are hidden, i.e. never
+// displayed and without tag page.
+const (
+	// PublicTag marks notes which are allowed to be published.
+	PublicTag = "#public"
+	// DraftTag prevents publishing a note, even if it is public.
+	DraftTag = "#draft"
+	// NoIndexTag excludes a note from search engines and the sitemap.
+	NoIndexTag = "#noindex"
+)
+
+var systemTags = []string{PublicTag, DraftTag, NoIndexTag}
+
+// Configured aliases, e.g. #golang -> #go.
+var tagAliases = make(map[string]string)
+
+// Hidden tags, i.e. the system tags and the configured ones.
+var hiddenTags = hiddenTagSet(nil)
+
+// Configured tags which look like hex colors, e.g. #b2b.
+var hexTags = make(map[string]struct{})
+var tagLock sync.RWMutex
+
+// SetHexTags configures tags which would otherwise be ignored as hex colors.
+// The leading # is optional.
+func SetHexTags(tags []string) {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+	hexTags = make(map[string]struct{})
+	for _, tag := range tags {
+		hexTags[normalizeTag(tag)] = struct{}{}
+	}
+}
+
+// SetHiddenTags configures tags which are hidden in addition to the system
+// tags. The leading # is optional.
+func SetHiddenTags(tags []string) {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+	hiddenTags = hiddenTagSet(tags)
+}
+
+func hiddenTagSet(tags []string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, tag := range append(systemTags, tags...) {
+		set[normalizeTag(tag)] = struct{}{}
+	}
+	return set
+}
+
+// IsHiddenTag checks if a canonical tag is never displayed.
+func IsHiddenTag(tag string) bool {
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+	_, found := hiddenTags[tag]
+	return found
+}
+
+// SetTagAliases configures aliases, mapping tags to their canonical tag. The
+// leading # is optional.
+func SetTagAliases(aliases map[string]string) {
+	tagLock.Lock()
+	defer tagLock.Unlock()
+	tagAliases = make(map[string]string)
+	for alias, tag := range aliases {
+		tagAliases[normalizeTag(alias)] = normalizeTag(tag)
+	}
+}
+
+// CanonicalTag returns the lower case tag and resolves aliases.
+func CanonicalTag(tag string) string {
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+	tag = strings.ToLower(tag)
+	if canonical, ok := tagAliases[tag]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// isTag filters tokens which match tagRegex, but are no tags.
+func isTag(token string) bool {
+	if numberRegex.MatchString(token) {
+		return false
+	}
+	if !colorRegex.MatchString(token) {
+		return true
+	}
+	tagLock.RLock()
+	defer tagLock.RUnlock()
+	_, found := hexTags[strings.ToLower(token)]
+	return found
+}
 
-	for _, tag := range matches {
-		if tag != "" {
-			// Triming is easier than using the matcher's group.
-			tag = strings.Trim(tag, " \n\r\t")
+// findTags returns the start and end of every tag in markdown. Code and
+// anchors in link destinations, e.g. [intro](#intro), are no tags.
+func findTags(markdown string) [][]int {
+	code := CodeRanges(markdown)
+	indices := [][]int{}
+	for _, index := range tagRegex.FindAllStringSubmatchIndex(markdown, -1) {
+		start, end := index[4], index[5]
+		if InRanges(code, start) {
+			continue
+		}
+		if prefix := index[2]; markdown[prefix:start] == "(" && prefix > 0 && markdown[prefix-1] == ']' {
+			continue
+		}
+		if isTag(markdown[start:end]) {
+			indices = append(indices, []int{start, end})
+		}
+	}
+	return indices
+}
+
+func normalizeTag(tag string) string {
+	return "#" + strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// GetTags is defined here to prevent cyclic import. It returns all canonical
+// tags in the order of their appearance, without hidden tags.
+func GetTags(data []byte) []string {
+	tags := []string{}
+	for _, tag := range allTags(data) {
+		if !IsHiddenTag(tag) {
 			tags = append(tags, tag)
 		}
 	}
+	return tags
+}
+
+// HasTag checks if the data contains the canonical tag, which can be hidden.
+func HasTag(data []byte, tag string) bool {
+	for _, t := range allTags(data) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func allTags(data []byte) []string {
+	markdown := string(data)
+
+	tags := []string{}
+	for _, index := range findTags(markdown) {
+		tags = append(tags, CanonicalTag(markdown[index[0]:index[1]]))
+	}
 
 	return tags
 }
+
+// ReplaceTags replaces every tag, including hidden ones, in markdown with the
+// result of the function, which receives the tag as written and its canonical
+// tag.
+func ReplaceTags(markdown string, replace func(tag string, canonical string) string) string {
+	var result strings.Builder
+	last := 0
+	for _, index := range findTags(markdown) {
+		tag := markdown[index[0]:index[1]]
+		result.WriteString(markdown[last:index[0]])
+		result.WriteString(replace(tag, CanonicalTag(tag)))
+		last = index[1]
+	}
+	result.WriteString(markdown[last:])
+	return result.String()
+}
+
+// ParentTags returns all ancestors of a hierarchical tag, i.e. #lang for
+// #lang/go, from the nearest to the root.
+func ParentTags(tag string) []string {
+	parents := []string{}
+	for i := strings.LastIndex(tag, "/"); i > 1; i = strings.LastIndex(tag, "/") {
+		tag = tag[:i]
+		parents = append(parents, tag)
+	}
+	return parents
+}
+
+// TagFilename returns the filename of the page of a tag with the given suffix,
+// e.g. tag-go.md. Since filenames must not contain slashes, these are replaced
+// by two dashes for hierarchical tags, i.e. tag-lang--go.md for #lang/go.
+func TagFilename(tag string, suffix string) string {
+	name := strings.TrimPrefix(tag, "#")
+	name = strings.ReplaceAll(name, "/", "--")
+	return "tag-" + name + suffix
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetTagsSkipsCodeAndAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"text", "About #go and #lang/go.\n\n#public", "[#go #lang/go]"},
+		{"anchor", "See [intro](#intro) and #go.", "[#go]"},
+		{"fenced code", "```c\n#include <stdio.h>\n```\n#go", "[#go]"},
+		{"tilde fence", "~~~\n#define FOO\n~~~\n#go", "[#go]"},
+		{"unclosed fence", "#go\n```\n#include <stdio.h>", "[#go]"},
+		{"indented code", "Text.\n\n    #include <stdio.h>\n\n#go", "[#go]"},
+		{"indented paragraph", "Text\n    #go", "[#go]"},
+		{"indented list item", "- item\n    #go", "[#go]"},
+		{"inline code", "Use `#include` and ``a `#b` c`` with #go.", "[#go]"},
+		{"unmatched backtick", "A ` and #go", "[#go]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := fmt.Sprint(GetTags([]byte(test.markdown)))
+			if got != test.want {
+				t.Errorf("GetTags(%q) = %s, want %s", test.markdown, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceTagsSkipsCodeAndAnchors(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"[intro](#intro) #go", "[intro](#intro) <#go>"},
+		{"```\n#include <stdio.h>\n```\n#go", "```\n#include <stdio.h>\n```\n<#go>"},
+		{"`#define` #go", "`#define` <#go>"},
+	}
+	for _, test := range tests {
+		got := ReplaceTags(test.markdown, func(tag string, canonical string) string {
+			return "<" + canonical + ">"
+		})
+		if got != test.want {
+			t.Errorf("ReplaceTags(%q) = %q, want %q", test.markdown, got, test.want)
+		}
+	}
+}
+
+func TestGetTagsIgnoresColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		hexTags  []string
+		want     string
+	}{
+		{"color before text", "Use #fff for the background.", nil, "[]"},
+		{"color after text", "Color is #fff", nil, "[]"},
+		{"color in parentheses", "A gray (#ffffff) box.", nil, "[]"},
+		{"color with alpha", "Transparent #ffffff80.", nil, "[]"},
+		{"color after colon", "color: #B2B", nil, "[]"},
+		{"configured hex tag", "color: #B2B", []string{"b2b"}, "[#b2b]"},
+		{"configured with hash", "About #decade", []string{"#Decade"}, "[#decade]"},
+		{"other lengths", "A #cafe and #beef in #c0ffee2.", nil, "[#cafe #beef #c0ffee2]"},
+		{"number", "Step #1 of #go", nil, "[#go]"},
+	}
+	defer SetHexTags(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetHexTags(test.hexTags)
+			got := fmt.Sprint(GetTags([]byte(test.markdown)))
+			if got != test.want {
+				t.Errorf("GetTags(%q) = %s, want %s", test.markdown, got, test.want)
+			}
+		})
+	}
+}
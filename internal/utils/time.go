@@ -1,6 +1,18 @@
 package utils
 
-import "time"
+import (
+	"path"
+	"regexp"
+	"time"
+)
+
+// Layouts accepted for the front matter date field.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
 
 func MustParseDuration(duration string) time.Duration {
 	d, err := time.ParseDuration(duration)
@@ -10,3 +22,37 @@ func MustParseDuration(duration string) time.Duration {
 
 	return d
 }
+
+// ParseDate parses a date from front matter in one of the supported layouts.
+func ParseDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NoteDate computes the publication date of a note. An explicit date in the
+// front matter takes precedence over the Zettelkasten timestamp prefix of the
+// filename, e.g. 202009010520 for 2020-09-01 05:20.
+func NoteDate(filename string, frontMatter map[string]string) (time.Time, bool) {
+	if date, ok := ParseDate(frontMatter["date"]); ok {
+		return date, true
+	}
+
+	rx := regexp.MustCompile(`^(\d{12})`)
+	matches := rx.FindStringSubmatch(path.Base(filename))
+	if len(matches) < 2 {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("200601021504", matches[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
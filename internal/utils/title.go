@@ -1,6 +1,9 @@
 package utils
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
 // AutoCaptialize replaces the beginning of each word in a string with its uppercase pendant.
 func AutoCaptialize(title string) string {
@@ -16,3 +19,14 @@ func AutoCaptialize(title string) string {
 	}
 	return strings.Join(capitalized, " ")
 }
+
+// FilenameTitle returns the title part of a filename, i.e. for the name
+// 202009010520 Index foo bar.md it returns `Index foo bar`.
+func FilenameTitle(filename string) string {
+	rx := regexp.MustCompile(`\d* ?(.*?)\.md`)
+	matches := rx.FindStringSubmatch(filename)
+	if len(matches) < 1 {
+		return filename
+	}
+	return matches[1]
+}
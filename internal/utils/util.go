@@ -13,3 +13,15 @@ func BuildInformation() string {
 	}
 	return buildInformation
 }
+
+// SplitList splits a comma-separated configuration value and ignores empty elements.
+func SplitList(value string) []string {
+	elements := []string{}
+	for _, element := range strings.Split(value, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}